@@ -134,6 +134,11 @@ type CryptoWorkerPool struct {
 	wg        sync.WaitGroup
 	aead      cipher.AEAD
 	noncePool sync.Pool
+
+	// Per-peer anti-replay state, see backend_hyper_replay.go.
+	masterKey []byte
+	peers     map[uint64]*peerCryptoState
+	peersMu   sync.RWMutex
 }
 
 var globalCryptoPool *CryptoWorkerPool
@@ -155,6 +160,8 @@ func initCryptoPool() {
 					return make([]byte, 12)
 				},
 			},
+			masterKey: key,
+			peers:     make(map[uint64]*peerCryptoState),
 		}
 		
 		// Start worker goroutines
@@ -219,81 +226,158 @@ type OutboundPath struct {
 	err    error
 }
 
-// HyperMultiDial - Dial multiple paths in parallel, pick fastest winner
-// C++ may track per-path congestion metadata in ring buffer
+// HappyEyeballsStaggerMin/Max give the suggested range for the delay
+// between successive dial attempts in HyperMultiDial's RFC 8305-style race.
+// The delay actually used is HyperMultiDialStagger, which defaults to the
+// minimum and is not clamped to this range.
+const (
+	HappyEyeballsStaggerMin = 100 * time.Millisecond
+	HappyEyeballsStaggerMax = 250 * time.Millisecond
+)
+
+// HyperMultiDialStagger is the delay between successive dial attempts;
+// exposed as a var rather than a const so callers can tune it.
+var HyperMultiDialStagger = HappyEyeballsStaggerMin
+
+// HyperMultiDial races candidate paths RFC 8305-style instead of firing
+// them all at once: sort v6/v4 interleaved (with one route JitterAwareRouter
+// has recorded as fast moved to the front), kick off the first dial,
+// start the next one after HyperMultiDialStagger if the previous hasn't
+// succeeded yet, and return the moment any dial succeeds, cancelling the
+// rest. This avoids holding onto 2-3 connections for the full dial
+// timeout and lets the picker exploit the router's learned metrics
+// instead of whichever goroutine the scheduler ran first.
 func HyperMultiDial(host string, paths []string) (winner string, conn net.Conn) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
 	if len(paths) == 0 {
 		// Fallback to HyperDialMulti behavior
 		return "", HyperDialMulti(host)
 	}
-	
-	// Dial all paths concurrently
-	pathChan := make(chan *OutboundPath, len(paths))
-	var wg sync.WaitGroup
-	
-	for _, path := range paths {
-		wg.Add(1)
-		go func(addr string) {
-			defer wg.Done()
-			
-			start := time.Now()
-			dialer := &net.Dialer{
-				Timeout: 3 * time.Second,
-			}
-			conn, err := dialer.DialContext(ctx, "tcp", addr)
-			latency := time.Since(start)
-			
-			pathChan <- &OutboundPath{
-				conn:    conn,
-				latency: latency,
-				err:     err,
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ordered := staggerOrder(paths)
+
+	type result struct {
+		path    string
+		outcome *OutboundPath
+	}
+	resultChan := make(chan result, len(ordered))
+	attemptCtx, cancelAttempts := context.WithCancel(ctx)
+	defer cancelAttempts()
+
+	dial := func(addr string) {
+		start := time.Now()
+		var c net.Conn
+		var err error
+		if isHTTP2Candidate(addr) {
+			c, err = dialHTTP2Candidate(attemptCtx, addr)
+		} else {
+			dialer := &net.Dialer{Timeout: 3 * time.Second}
+			c, err = dialer.DialContext(attemptCtx, "tcp", addr)
+		}
+		latency := time.Since(start)
+		select {
+		case resultChan <- result{path: addr, outcome: &OutboundPath{conn: c, latency: latency, err: err}}:
+		case <-attemptCtx.Done():
+			if c != nil {
+				c.Close()
 			}
-		}(path)
+		}
 	}
-	
-	// Wait for all dials, collect fastest
-	wg.Wait()
-	close(pathChan)
-	
-	var fastest *OutboundPath
-	var fastestPath string
-	pathIdx := 0
-	
-	for path := range pathChan {
-		currentPath := paths[pathIdx]
-		pathIdx++
-		
-		if path.err != nil {
-			if path.conn != nil {
-				path.conn.Close()
+
+	go func() {
+		for i, addr := range ordered {
+			if i > 0 {
+				timer := time.NewTimer(HyperMultiDialStagger)
+				select {
+				case <-timer.C:
+				case <-attemptCtx.Done():
+					timer.Stop()
+					return
+				}
 			}
-			continue
+			go dial(addr)
 		}
-		if fastest == nil || path.latency < fastest.latency {
-			if fastest != nil && fastest.conn != nil {
-				fastest.conn.Close()
+	}()
+
+	pending := len(ordered)
+	for pending > 0 {
+		select {
+		case res := <-resultChan:
+			pending--
+			if res.outcome.err != nil {
+				continue
 			}
-			fastest = path
-			fastestPath = currentPath
-		} else if path.conn != nil {
-			path.conn.Close()
+
+			cancelAttempts() // stop the remaining in-flight dials
+
+			initRouter()
+			globalRouter.UpdateRoute(res.path, res.outcome.latency, 0)
+
+			if tcpConn, ok := res.outcome.conn.(*net.TCPConn); ok {
+				tcpConn.SetNoDelay(true)
+				tcpConn.SetKeepAlive(true)
+				tcpConn.SetKeepAlivePeriod(KeepaliveMinInterval)
+			}
+			// Pin the winner's egress so a later route flap can't
+			// silently reroute this flow out from under the peer's NAT
+			// binding.
+			return res.path, HyperPinWinner(res.path, res.outcome.conn)
+
+		case <-ctx.Done():
+			return "", nil
 		}
 	}
-	
-	if fastest != nil && fastest.conn != nil {
-		// Set TCP_NODELAY and socket options for low latency
-		if tcpConn, ok := fastest.conn.(*net.TCPConn); ok {
-			tcpConn.SetNoDelay(true)
-			tcpConn.SetKeepAlive(true)
-			tcpConn.SetKeepAlivePeriod(KeepaliveMinInterval)
+
+	return "", nil
+}
+
+// staggerOrder sorts candidate addresses IPv6-then-IPv4 interleaved per
+// RFC 8305, then swaps to the front the last address (in that order) whose
+// latency JitterAwareRouter recorded below HyperMultiDialStagger, so the
+// race exploits learned metrics instead of starting cold every time. At
+// most one address is moved.
+func staggerOrder(paths []string) []string {
+	var v6, v4, other []string
+	for _, p := range paths {
+		host, _, err := net.SplitHostPort(p)
+		if err != nil {
+			other = append(other, p)
+			continue
+		}
+		ip := net.ParseIP(host)
+		switch {
+		case ip == nil:
+			other = append(other, p)
+		case ip.To4() != nil:
+			v4 = append(v4, p)
+		default:
+			v6 = append(v6, p)
 		}
-		return fastestPath, fastest.conn
 	}
-	
-	return "", nil
+
+	ordered := make([]string, 0, len(paths))
+	for i := 0; i < len(v6) || i < len(v4); i++ {
+		if i < len(v6) {
+			ordered = append(ordered, v6[i])
+		}
+		if i < len(v4) {
+			ordered = append(ordered, v4[i])
+		}
+	}
+	ordered = append(ordered, other...)
+
+	initRouter()
+	globalRouter.mu.RLock()
+	defer globalRouter.mu.RUnlock()
+	for i := len(ordered) - 1; i > 0; i-- {
+		if metric, ok := globalRouter.routes[ordered[i]]; ok && metric.latency > 0 && metric.latency < HyperMultiDialStagger {
+			ordered[0], ordered[i] = ordered[i], ordered[0]
+			break
+		}
+	}
+	return ordered
 }
 
 // HyperDialMulti - Dial multiple paths in parallel, return fastest
@@ -396,6 +480,11 @@ type KeepaliveManager struct {
 	keepalive []byte
 	ticker    *time.Ticker
 	stop      chan struct{}
+
+	// STUN-based NAT traversal state, see backend_hyper_stun.go.
+	stunServers    []string
+	stunState      map[net.Conn]*stunConnState
+	onBindingChanged func(conn net.Conn, newAddr *net.UDPAddr)
 }
 
 var globalKeepalive *KeepaliveManager
@@ -404,10 +493,12 @@ var keepaliveOnce sync.Once
 func initKeepalive() {
 	keepaliveOnce.Do(func() {
 		globalKeepalive = &KeepaliveManager{
-			conns:     make(map[net.Conn]time.Time),
-			interval:  KeepaliveMinInterval,
-			keepalive: []byte{0x00, 0x00, 0x00, 0x00}, // Keepalive packet
-			stop:      make(chan struct{}),
+			conns:       make(map[net.Conn]time.Time),
+			interval:    KeepaliveMinInterval,
+			keepalive:   []byte{0x00, 0x00, 0x00, 0x00}, // Keepalive packet
+			stop:        make(chan struct{}),
+			stunServers: defaultSTUNServers,
+			stunState:   make(map[net.Conn]*stunConnState),
 		}
 		globalKeepalive.ticker = time.NewTicker(globalKeepalive.interval)
 		go globalKeepalive.run()
@@ -439,8 +530,7 @@ func (km *KeepaliveManager) sendKeepalives() {
 		wg.Add(1)
 		go func(c net.Conn) {
 			defer wg.Done()
-			c.SetWriteDeadline(time.Now().Add(1 * time.Second))
-			c.Write(km.keepalive)
+			km.sendKeepaliveTo(c)
 		}(conn)
 	}
 	wg.Wait()
@@ -450,12 +540,17 @@ func (km *KeepaliveManager) Add(conn net.Conn) {
 	km.mu.Lock()
 	defer km.mu.Unlock()
 	km.conns[conn] = time.Now()
+	if udpConn, ok := conn.(*net.UDPConn); ok {
+		km.stunState[conn] = &stunConnState{udpConn: udpConn}
+		go km.probeSTUN(conn)
+	}
 }
 
 func (km *KeepaliveManager) Remove(conn net.Conn) {
 	km.mu.Lock()
 	defer km.mu.Unlock()
 	delete(km.conns, conn)
+	delete(km.stunState, conn)
 }
 
 // HyperBurstKeepalive - Register connection for aggressive keepalive
@@ -668,25 +763,8 @@ func (r *JitterAwareRouter) UpdateRoute(routeID string, latency time.Duration, l
 	metric.lastUpdate = time.Now()
 }
 
-func (r *JitterAwareRouter) SelectBestRoute() string {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	
-	var bestRoute string
-	var bestScore float64 = -1
-	
-	for routeID, metric := range r.routes {
-		// Score = 1 / (latency + jitter*2 + loss*1000)
-		// Lower latency, jitter, and loss = higher score
-		score := 1.0 / (float64(metric.latency) + float64(metric.jitter)*2 + metric.packetLoss*1000)
-		if score > bestScore {
-			bestScore = score
-			bestRoute = routeID
-		}
-	}
-	
-	return bestRoute
-}
+// SelectBestRoute is implemented in backend_hyper_sticky.go, which also
+// biases the pick toward an already-pinned sticky route.
 
 // ============================================================================
 // TCP BBRv2 Pacing Profile
@@ -871,6 +949,15 @@ func (mb *Microbatch) Schedule(task func()) {
 // Helper functions
 // ============================================================================
 
+// udpGSOPacketsSent counts packets successfully sent through
+// HyperBatchSendUDP (the GSO/sendmmsg fast path on Linux, per-packet sends
+// elsewhere), for diagnostics alongside the other Hyper* counters.
+var udpGSOPacketsSent uint64
+
+func recordUDPGSOSend(n int) {
+	atomic.AddUint64(&udpGSOPacketsSent, uint64(n))
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -963,7 +1050,7 @@ func HyperAESGCM(plaintext, key, iv []byte) ([]byte, []byte, error) {
 // HookDispatchLoop - Integrate into packet dispatch loop
 func HookDispatchLoop(processPacket func([]byte)) {
 	initMicrobatch()
-	
+
 	// Process packets in microbatches
 	globalMicrobatch.Schedule(func() {
 		// This will be called in batch with other tasks
@@ -971,6 +1058,20 @@ func HookDispatchLoop(processPacket func([]byte)) {
 	})
 }
 
+// HookDispatchLoopUDP - Integrate a UDP flow into the microbatch scheduler
+// so a group of packets to the same 5-tuple is handed to HyperBatchSendUDP
+// (one GSO sendmsg per run of equal-length packets, or one sendmmsg)
+// instead of waking the dispatch loop per packet.
+func HookDispatchLoopUDP(conn *net.UDPConn, dst *net.UDPAddr, packets [][]byte) {
+	initMicrobatch()
+
+	globalMicrobatch.Schedule(func() {
+		if err := HyperBatchSendUDP(conn, dst, packets); err == nil {
+			recordUDPGSOSend(len(packets))
+		}
+	})
+}
+
 // HookCryptoPipeline - Integrate into crypto pipeline
 func HookCryptoPipeline(plaintext []byte) []byte {
 	return HyperParallelCrypto(plaintext)
@@ -986,6 +1087,17 @@ func HookOutboundSelector(host string) net.Conn {
 	return HyperDialMulti(host)
 }
 
+// HookOutboundSelectorMulti - like HookOutboundSelector, but lets the
+// caller offer an explicit set of candidate paths (plain "host:port" for
+// TCP, "h2://host/path" for the streaming HTTP/2 POST transport) so the
+// JitterAwareRouter-driven race can pick TCP or HTTP/2 instead of always
+// defaulting to TCP. There is no QUIC candidate kind: any candidate
+// without the "h2://" prefix is dialed as plain TCP.
+func HookOutboundSelectorMulti(host string, candidates []string) net.Conn {
+	_, conn := HyperMultiDial(host, candidates)
+	return conn
+}
+
 // HookHandshakePreflight - Integrate into handshake preflight
 func HookHandshakePreflight(host string) error {
 	return HyperWarmQUIC(host)
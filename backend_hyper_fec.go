@@ -0,0 +1,508 @@
+// +build ignore
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Forward Error Correction (HyperFEC) - KCP-style Reed-Solomon over GF(2^8)
+// ============================================================================
+//
+// Sits between the crypto pool and the outbound dialer. The sender groups N
+// consecutive packets into a shard group and emits K systematic parity
+// shards; the receiver reconstructs any missing shard as long as at least N
+// of the N+K shards arrive. (N, K) is adaptive: it tracks MSSClamper.lossRate
+// and BBRv2Pacer's RTT variance and scales K up as loss rises past
+// MSSClampLossThreshold, back down as loss subsides.
+
+const (
+	fecMinDataShards   = 4
+	fecMaxDataShards   = 16
+	fecMinParityShards = 0
+	fecMaxParityShards = 8
+
+	// fecRingSize bounds how many in-flight groups the receiver tracks.
+	fecRingSize = 64
+
+	fecHeaderSize = 4 + 1 + 1 + 1 // group id, shard index, data count, parity count
+)
+
+// ----------------------------------------------------------------------------
+// GF(2^8) arithmetic (polynomial 0x11d with generator 2, as in common
+// RS-over-GF(256) codecs; not the Rijndael polynomial 0x11b)
+// ----------------------------------------------------------------------------
+
+var (
+	gfExp [512]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d // x^8 + x^4 + x^3 + x^2 + 1
+		}
+	}
+	for i := 255; i < 512; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])-int(gfLog[b])+255]
+}
+
+// encodingMatrix builds an (dataShards+parityShards) x dataShards matrix
+// whose top dataShards rows are the identity (systematic code) and whose
+// remaining parityShards rows come from a Cauchy matrix: row r, column c is
+// 1/(x_r ^ y_c) for disjoint point sets x_r = dataShards+r, y_c = c. Unlike
+// a plain Vandermonde [I; V] (which is only MDS for some shard counts -
+// some square submatrices of V are singular, so a subset with enough
+// shards present could still fail to decode), every square submatrix of a
+// Cauchy matrix is invertible, so this code really does reconstruct from
+// any dataShards-out-of-total shards as the adaptive (N, K) range promises.
+func encodingMatrix(dataShards, parityShards int) [][]byte {
+	total := dataShards + parityShards
+	m := make([][]byte, total)
+	for r := 0; r < dataShards; r++ {
+		m[r] = make([]byte, dataShards)
+		m[r][r] = 1
+	}
+	for r := 0; r < parityShards; r++ {
+		row := make([]byte, dataShards)
+		x := byte(dataShards + r)
+		for c := 0; c < dataShards; c++ {
+			row[c] = gfDiv(1, x^byte(c))
+		}
+		m[dataShards+r] = row
+	}
+	return m
+}
+
+// invertMatrix inverts a square GF(256) matrix via Gauss-Jordan elimination.
+func invertMatrix(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	aug := make([][]byte, n)
+	for i := range aug {
+		aug[i] = make([]byte, 2*n)
+		copy(aug[i], m[i])
+		aug[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for row := col; row < n; row++ {
+			if aug[row][col] != 0 {
+				pivot = row
+				break
+			}
+		}
+		if pivot == -1 {
+			return nil, fmt.Errorf("fec: matrix is singular, cannot reconstruct")
+		}
+		aug[col], aug[pivot] = aug[pivot], aug[col]
+
+		inv := gfDiv(1, aug[col][col])
+		for c := 0; c < 2*n; c++ {
+			aug[col][c] = gfMul(aug[col][c], inv)
+		}
+		for row := 0; row < n; row++ {
+			if row == col || aug[row][col] == 0 {
+				continue
+			}
+			factor := aug[row][col]
+			for c := 0; c < 2*n; c++ {
+				aug[row][c] ^= gfMul(factor, aug[col][c])
+			}
+		}
+	}
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = aug[i][n:]
+	}
+	return out, nil
+}
+
+// ----------------------------------------------------------------------------
+// Sender: systematic RS encode
+// ----------------------------------------------------------------------------
+
+// fecEncode produces parityShards parity slices from dataShards equally
+// sized data slices, using the Cauchy rows of the encoding matrix.
+func fecEncode(dataShards [][]byte, parityShards int) ([][]byte, error) {
+	n := len(dataShards)
+	if n == 0 {
+		return nil, fmt.Errorf("fec: no data shards")
+	}
+	shardLen := len(dataShards[0])
+	for _, d := range dataShards {
+		if len(d) != shardLen {
+			return nil, fmt.Errorf("fec: data shards must be equal length")
+		}
+	}
+	matrix := encodingMatrix(n, parityShards)
+	parity := make([][]byte, parityShards)
+	for p := 0; p < parityShards; p++ {
+		row := matrix[n+p]
+		out := make([]byte, shardLen)
+		for c := 0; c < n; c++ {
+			if row[c] == 0 {
+				continue
+			}
+			coeff := row[c]
+			src := dataShards[c]
+			for i := 0; i < shardLen; i++ {
+				out[i] ^= gfMul(coeff, src[i])
+			}
+		}
+		parity[p] = out
+	}
+	return parity, nil
+}
+
+// fecReconstruct fills in missing data shards given whatever data+parity
+// shards are present, as long as at least dataShards of them arrived.
+func fecReconstruct(shards [][]byte, present []bool, dataShards, parityShards int) error {
+	total := dataShards + parityShards
+	haveCount := 0
+	for _, ok := range present {
+		if ok {
+			haveCount++
+		}
+	}
+	if haveCount < dataShards {
+		return fmt.Errorf("fec: only %d/%d shards present, need %d", haveCount, total, dataShards)
+	}
+	missingData := false
+	for i := 0; i < dataShards; i++ {
+		if !present[i] {
+			missingData = true
+			break
+		}
+	}
+	if !missingData {
+		return nil
+	}
+
+	full := encodingMatrix(dataShards, parityShards)
+	shardLen := 0
+	for i, ok := range present {
+		if ok && len(shards[i]) > shardLen {
+			shardLen = len(shards[i])
+		}
+	}
+
+	sub := make([][]byte, 0, dataShards)
+	used := make([]int, 0, dataShards)
+	for i := 0; i < total && len(sub) < dataShards; i++ {
+		if present[i] {
+			sub = append(sub, full[i])
+			used = append(used, i)
+		}
+	}
+	inv, err := invertMatrix(sub)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < dataShards; i++ {
+		if present[i] {
+			continue
+		}
+		out := make([]byte, shardLen)
+		for j, srcIdx := range used {
+			coeff := inv[i][j]
+			if coeff == 0 {
+				continue
+			}
+			src := shards[srcIdx]
+			for b := 0; b < len(src); b++ {
+				out[b] ^= gfMul(coeff, src[b])
+			}
+		}
+		shards[i] = out
+		present[i] = true
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// Adaptive (N, K) selection, driven by MSSClamper and BBRv2Pacer
+// ----------------------------------------------------------------------------
+
+// HyperFECConfig holds the current adaptive shard counts.
+type HyperFECConfig struct {
+	mu           sync.RWMutex
+	dataShards   int
+	parityShards int
+}
+
+var globalFEC = &HyperFECConfig{dataShards: fecMinDataShards, parityShards: fecMinParityShards}
+
+// hyperFECAdapt recomputes (N, K) from the current loss estimate and RTT
+// variance. K rises as loss climbs past MSSClampLossThreshold, and falls
+// back down as loss subsides; N stays at a fixed group size chosen by RTT
+// variance (tighter groups when jitter is high, to bound flush latency).
+func hyperFECAdapt() {
+	initMSS()
+	initBBR()
+
+	lossRate := globalMSS.lossRate
+	globalBBR.mu.Lock()
+	rttVariance := time.Duration(0)
+	if len(globalBBR.rttWindow) > 0 {
+		avg := time.Duration(0)
+		for _, r := range globalBBR.rttWindow {
+			avg += r
+		}
+		avg /= time.Duration(len(globalBBR.rttWindow))
+		rttVariance = avg - globalBBR.minRTT
+	}
+	globalBBR.mu.Unlock()
+
+	parity := fecMinParityShards
+	switch {
+	case lossRate > MSSClampLossThreshold*4:
+		parity = fecMaxParityShards
+	case lossRate > MSSClampLossThreshold*2:
+		parity = fecMaxParityShards / 2
+	case lossRate > MSSClampLossThreshold:
+		parity = 2
+	}
+
+	data := fecMaxDataShards
+	if rttVariance > globalBBR.minRTT {
+		// Jittery path: keep groups small so a flush timeout doesn't stall
+		// too many packets waiting on the last shard.
+		data = fecMinDataShards
+	}
+
+	globalFEC.mu.Lock()
+	globalFEC.dataShards = data
+	globalFEC.parityShards = parity
+	globalFEC.mu.Unlock()
+}
+
+func (c *HyperFECConfig) current() (int, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dataShards, c.parityShards
+}
+
+// ----------------------------------------------------------------------------
+// Wire format: group id (uint32), shard index (uint8), data count (uint8),
+// parity count (uint8), followed by the shard payload.
+// ----------------------------------------------------------------------------
+
+func fecEncodeHeader(groupID uint32, shardIdx, dataCount, parityCount uint8, payload []byte) []byte {
+	out := make([]byte, fecHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(out[0:4], groupID)
+	out[4] = shardIdx
+	out[5] = dataCount
+	out[6] = parityCount
+	copy(out[fecHeaderSize:], payload)
+	return out
+}
+
+func fecDecodeHeader(pkt []byte) (groupID uint32, shardIdx, dataCount, parityCount uint8, payload []byte, ok bool) {
+	if len(pkt) < fecHeaderSize {
+		return 0, 0, 0, 0, nil, false
+	}
+	groupID = binary.BigEndian.Uint32(pkt[0:4])
+	shardIdx = pkt[4]
+	dataCount = pkt[5]
+	parityCount = pkt[6]
+	payload = pkt[fecHeaderSize:]
+	return groupID, shardIdx, dataCount, parityCount, payload, true
+}
+
+// HyperFECEncode takes data already split into shards, computes parity
+// shards, and frames every shard with the group header. It does not split
+// data itself: len(data) must equal the current adaptive data-shard count
+// (else an error is returned), and when parity is in use every shard must
+// have the same length. groupID should increment per call.
+func HyperFECEncode(groupID uint32, data [][]byte) ([][]byte, error) {
+	hyperFECAdapt()
+	dataShards, parityShards := globalFEC.current()
+	if len(data) != dataShards {
+		return nil, fmt.Errorf("fec: expected %d data shards, got %d", dataShards, len(data))
+	}
+
+	var framed [][]byte
+	for i, d := range data {
+		framed = append(framed, fecEncodeHeader(groupID, uint8(i), uint8(dataShards), uint8(parityShards), d))
+	}
+	if parityShards > 0 {
+		parity, err := fecEncode(data, parityShards)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range parity {
+			framed = append(framed, fecEncodeHeader(groupID, uint8(dataShards+i), uint8(dataShards), uint8(parityShards), p))
+		}
+	}
+	return framed, nil
+}
+
+// ----------------------------------------------------------------------------
+// Receiver: ring buffer of in-flight groups
+// ----------------------------------------------------------------------------
+
+type fecGroup struct {
+	groupID      uint32
+	dataShards   int
+	parityShards int
+	shards       [][]byte
+	present      []bool
+	haveData     int
+	createdAt    time.Time
+}
+
+// HyperFECReceiver reassembles shard groups and reconstructs missing data
+// shards once enough of the group has arrived.
+type HyperFECReceiver struct {
+	mu      sync.Mutex
+	ring    map[uint32]*fecGroup
+	onGroup func(groupID uint32, data [][]byte)
+}
+
+// NewHyperFECReceiver creates a receiver that calls onGroup with the
+// reassembled data shards whenever a group completes, fills to N data
+// shards, or times out with enough shards to reconstruct; a timed-out
+// group that cannot be reconstructed is dropped without calling onGroup.
+func NewHyperFECReceiver(onGroup func(groupID uint32, data [][]byte)) *HyperFECReceiver {
+	return &HyperFECReceiver{ring: make(map[uint32]*fecGroup), onGroup: onGroup}
+}
+
+// Feed hands one received shard packet to the receiver.
+func (r *HyperFECReceiver) Feed(pkt []byte) error {
+	groupID, shardIdx, dataCount, parityCount, payload, ok := fecDecodeHeader(pkt)
+	if !ok {
+		return fmt.Errorf("fec: short packet")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g, exists := r.ring[groupID]
+	if !exists {
+		if len(r.ring) >= fecRingSize {
+			r.evictOldestLocked()
+		}
+		g = &fecGroup{
+			groupID:      groupID,
+			dataShards:   int(dataCount),
+			parityShards: int(parityCount),
+			shards:       make([][]byte, int(dataCount)+int(parityCount)),
+			present:      make([]bool, int(dataCount)+int(parityCount)),
+			createdAt:    time.Now(),
+		}
+		r.ring[groupID] = g
+	}
+
+	if int(shardIdx) >= len(g.shards) || g.present[shardIdx] {
+		return nil
+	}
+	g.shards[shardIdx] = payload
+	g.present[shardIdx] = true
+	if int(shardIdx) < g.dataShards {
+		g.haveData++
+	}
+
+	if g.haveData == g.dataShards {
+		r.flushLocked(g)
+		return nil
+	}
+
+	haveTotal := 0
+	for _, p := range g.present {
+		if p {
+			haveTotal++
+		}
+	}
+	if haveTotal >= g.dataShards {
+		if err := fecReconstruct(g.shards, g.present, g.dataShards, g.parityShards); err == nil {
+			r.flushLocked(g)
+		}
+	}
+	return nil
+}
+
+// flushLocked delivers the reassembled data shards and removes the group
+// from the ring. Caller must hold r.mu.
+func (r *HyperFECReceiver) flushLocked(g *fecGroup) {
+	data := make([][]byte, g.dataShards)
+	copy(data, g.shards[:g.dataShards])
+	delete(r.ring, g.groupID)
+	if r.onGroup != nil {
+		r.onGroup(g.groupID, data)
+	}
+}
+
+func (r *HyperFECReceiver) evictOldestLocked() {
+	var oldestID uint32
+	var oldestAt time.Time
+	first := true
+	for id, g := range r.ring {
+		if first || g.createdAt.Before(oldestAt) {
+			oldestID, oldestAt, first = id, g.createdAt, false
+		}
+	}
+	if !first {
+		delete(r.ring, oldestID)
+	}
+}
+
+// SweepTimeouts handles every group older than a BBRv2Pacer.minRTT-derived
+// timeout: it is flushed if its missing data shards can be reconstructed,
+// and otherwise dropped without calling onGroup. Callers should run this
+// periodically, e.g. from the Microbatch ticker.
+func (r *HyperFECReceiver) SweepTimeouts() {
+	initBBR()
+	globalBBR.mu.Lock()
+	timeout := globalBBR.minRTT * 4
+	globalBBR.mu.Unlock()
+	if timeout <= 0 {
+		timeout = 400 * time.Millisecond
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, g := range r.ring {
+		if now.Sub(g.createdAt) < timeout {
+			continue
+		}
+		if err := fecReconstruct(g.shards, g.present, g.dataShards, g.parityShards); err == nil {
+			r.flushLocked(g)
+		} else {
+			delete(r.ring, id)
+		}
+	}
+}
+
+// HookFECScheduler wires HyperFECReceiver.SweepTimeouts into the
+// microbatch scheduler so stale groups get flushed without a dedicated
+// goroutine per receiver.
+func HookFECScheduler(r *HyperFECReceiver) {
+	initMicrobatch()
+	globalMicrobatch.Schedule(r.SweepTimeouts)
+}
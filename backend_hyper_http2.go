@@ -0,0 +1,161 @@
+// +build ignore
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Streaming HTTP/2 POST transport mode
+// ============================================================================
+//
+// Tunnels the batched packet stream through an HTTP/2 long-lived POST
+// request (no WebSocket framing, no Upgrade, no CONNECT)
+// instead of raw TCP, reusing net/http's Transport connection pool and TLS
+// session resumption across dials. Each logical flow is a single request
+// whose body is the framed HyperBatch stream; the response body is the
+// return stream. Candidates prefixed "h2://host/path" in the paths passed
+// to HyperMultiDial race through this transport alongside plain TCP, and
+// the Content-Type/no-Upgrade-header shape keeps it looking like an
+// ordinary HTTPS request so it survives CDN front-ends.
+
+const http2CandidateScheme = "h2://"
+
+// sharedHTTP2Transport is reused across dials so TLS session resumption
+// and HTTP/2 connection pooling actually pay off.
+var sharedHTTP2Transport = &http.Transport{
+	ForceAttemptHTTP2:   true,
+	MaxIdleConnsPerHost: MaxOutboundPaths,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+// httpStreamConn adapts an HTTP/2 request/response body pair to net.Conn so
+// it can be raced and used exactly like a TCP connection elsewhere in the
+// Hyper dialer.
+type httpStreamConn struct {
+	reqBodyWriter *io.PipeWriter
+	respBody      io.ReadCloser
+	localAddr     net.Addr
+	remoteAddr    net.Addr
+}
+
+func (c *httpStreamConn) Read(b []byte) (int, error)  { return c.respBody.Read(b) }
+func (c *httpStreamConn) Write(b []byte) (int, error) { return c.reqBodyWriter.Write(b) }
+func (c *httpStreamConn) Close() error {
+	werr := c.reqBodyWriter.Close()
+	rerr := c.respBody.Close()
+	if werr != nil {
+		return werr
+	}
+	return rerr
+}
+func (c *httpStreamConn) LocalAddr() net.Addr  { return c.localAddr }
+func (c *httpStreamConn) RemoteAddr() net.Addr { return c.remoteAddr }
+
+// Deadlines aren't meaningfully supported over an HTTP/2 stream body; the
+// Transport's own timeouts govern the underlying connection instead.
+func (c *httpStreamConn) SetDeadline(t time.Time) error      { return nil }
+func (c *httpStreamConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *httpStreamConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// httpAddr satisfies net.Addr for the synthetic HTTP/2 stream endpoints.
+type httpAddr string
+
+func (a httpAddr) Network() string { return "http2" }
+func (a httpAddr) String() string  { return string(a) }
+
+// HyperDialHTTP2 opens an HTTP/2 long-lived request to host/path and
+// returns a net.Conn adapter over its request/response body pair. The
+// request looks like an ordinary HTTPS POST (no Upgrade header) so it
+// passes through CDN front-ends that only forward regular HTTP/2 traffic.
+func HyperDialHTTP2(host, path string) (net.Conn, error) {
+	if path == "" {
+		path = "/"
+	}
+	reqBodyReader, reqBodyWriter := io.Pipe()
+
+	u := url.URL{Scheme: "https", Host: host, Path: path}
+	req, err := http.NewRequest(http.MethodPost, u.String(), reqBodyReader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.ContentLength = -1 // streamed body, unknown length
+
+	client := &http.Client{Transport: sharedHTTP2Transport}
+
+	respChan := make(chan *http.Response, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		resp, err := client.Do(req)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		respChan <- resp
+	}()
+
+	select {
+	case err := <-errChan:
+		reqBodyWriter.Close()
+		return nil, fmt.Errorf("h2 dial %s%s: %w", host, path, err)
+	case resp := <-respChan:
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			reqBodyWriter.Close()
+			return nil, fmt.Errorf("h2 dial %s%s: unexpected status %d", host, path, resp.StatusCode)
+		}
+		return &httpStreamConn{
+			reqBodyWriter: reqBodyWriter,
+			respBody:      resp.Body,
+			localAddr:     httpAddr("h2-local"),
+			remoteAddr:    httpAddr(host + path),
+		}, nil
+	case <-time.After(5 * time.Second):
+		reqBodyWriter.Close()
+		return nil, fmt.Errorf("h2 dial %s%s: handshake timeout", host, path)
+	}
+}
+
+// isHTTP2Candidate reports whether addr is an "h2://host/path" racing
+// candidate rather than a plain TCP host:port.
+func isHTTP2Candidate(addr string) bool {
+	return strings.HasPrefix(addr, http2CandidateScheme)
+}
+
+// dialHTTP2Candidate dials an "h2://host/path" candidate produced by
+// staggerOrder/HyperMultiDial's caller.
+func dialHTTP2Candidate(ctx context.Context, addr string) (net.Conn, error) {
+	rest := strings.TrimPrefix(addr, http2CandidateScheme)
+	host := rest
+	path := "/"
+	if idx := strings.IndexByte(rest, '/'); idx >= 0 {
+		host = rest[:idx]
+		path = rest[idx:]
+	}
+
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan dialResult, 1)
+	go func() {
+		conn, err := HyperDialHTTP2(host, path)
+		done <- dialResult{conn, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,248 @@
+// +build ignore
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// ============================================================================
+// Anti-replay sliding window with per-peer nonces for CryptoWorkerPool
+// ============================================================================
+//
+// HyperParallelCrypto generates a random 12-byte nonce per packet with no
+// replay protection or per-peer key separation, and is left as it is.
+// Encrypt/Decrypt below add WireGuard-style transport framing alongside
+// it: a per-peer monotonically increasing
+// 64-bit counter forms the nonce (4 zero bytes + 8-byte little-endian
+// counter), and the receive side tracks acceptance with a 2048-bit sliding
+// window bitmap keyed by peer id. Counter allocation and window updates are
+// lock-free: the counter via atomic.AddUint64, the window via a per-peer
+// spinlock so concurrent workers never block on a global mutex.
+
+const replayWindowBits = 2048
+const replayWindowWords = replayWindowBits / 64
+
+// peerCryptoState holds the per-peer AEAD key, send counter, and receive
+// replay window.
+type peerCryptoState struct {
+	aead    cipherAEAD
+	counter uint64 // atomic, next nonce to send
+
+	lock   int32 // spinlock guarding high/bitmap
+	high   uint64
+	bitmap [replayWindowWords]uint64
+}
+
+// cipherAEAD narrows the import surface to just what Encrypt/Decrypt need.
+type cipherAEAD interface {
+	Seal(dst, nonce, plaintext, additionalData []byte) []byte
+	Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error)
+	NonceSize() int
+	Overhead() int
+}
+
+func (p *peerCryptoState) spinLock() {
+	for !atomic.CompareAndSwapInt32(&p.lock, 0, 1) {
+		runtime.Gosched()
+	}
+}
+
+func (p *peerCryptoState) spinUnlock() {
+	atomic.StoreInt32(&p.lock, 0)
+}
+
+// derivePeerKey derives a per-peer AEAD key from the pool's master key and
+// the peer id, giving every peer key separation without a full handshake.
+func derivePeerKey(masterKey []byte, peerID uint64) ([]byte, error) {
+	h := sha256.New()
+	h.Write(masterKey)
+	var idBuf [8]byte
+	binary.BigEndian.PutUint64(idBuf[:], peerID)
+	h.Write(idBuf[:])
+	return h.Sum(nil), nil
+}
+
+// peerState returns (creating if necessary) the crypto state for peerID.
+func (p *CryptoWorkerPool) peerState(peerID uint64) (*peerCryptoState, error) {
+	p.peersMu.RLock()
+	state, ok := p.peers[peerID]
+	p.peersMu.RUnlock()
+	if ok {
+		return state, nil
+	}
+
+	p.peersMu.Lock()
+	defer p.peersMu.Unlock()
+	if state, ok = p.peers[peerID]; ok {
+		return state, nil
+	}
+
+	key, err := derivePeerKey(p.masterKey, peerID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	state = &peerCryptoState{aead: aead}
+	p.peers[peerID] = state
+	return state, nil
+}
+
+// nonceFromCounter builds the 12-byte ChaCha20-Poly1305 nonce used by the
+// anti-replay transport: 4 zero bytes followed by the 8-byte little-endian
+// counter, mirroring WireGuard's transport nonce layout.
+func nonceFromCounter(counter uint64) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint64(nonce[4:], counter)
+	return nonce
+}
+
+// Encrypt seals plaintext for peerID using the next counter value,
+// prepending the 8-byte counter in the clear so the receiver can check it
+// against the sliding window before decrypting.
+func (p *CryptoWorkerPool) Encrypt(peerID uint64, plaintext []byte) ([]byte, error) {
+	state, err := p.peerState(peerID)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := atomic.AddUint64(&state.counter, 1) - 1
+	nonce := nonceFromCounter(counter)
+
+	out := make([]byte, 8, 8+len(plaintext)+state.aead.Overhead())
+	binary.LittleEndian.PutUint64(out, counter)
+	out = state.aead.Seal(out, nonce, plaintext, nil)
+	return out, nil
+}
+
+// Decrypt validates ciphertext's counter against the peer's sliding
+// replay window, opens it, and (only on success) re-checks-and-marks the
+// counter seen in one atomic step. The cheap pre-Open replayCheck is just
+// an optimization to skip AEAD work on obviously stale/duplicate counters;
+// it does not mutate state, so it can't be used to decide acceptance by
+// itself - two packets with the same counter would both pass it and both
+// reach Open. The authoritative decision is replayCheckAndAccept, made
+// after Open has already authenticated the packet.
+func (p *CryptoWorkerPool) Decrypt(peerID uint64, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 8 {
+		return nil, fmt.Errorf("crypto: ciphertext too short")
+	}
+	state, err := p.peerState(peerID)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := binary.LittleEndian.Uint64(ciphertext[:8])
+	if !state.replayCheck(counter) {
+		return nil, fmt.Errorf("crypto: replayed or stale counter %d", counter)
+	}
+
+	nonce := nonceFromCounter(counter)
+	plaintext, err := state.aead.Open(nil, nonce, ciphertext[8:], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if !state.replayCheckAndAccept(counter) {
+		return nil, fmt.Errorf("crypto: replayed counter %d", counter)
+	}
+	return plaintext, nil
+}
+
+// replayCheck reports whether counter is acceptable without mutating state:
+// rejected if it falls behind the window (C+2048 <= H) or its bit is
+// already set. This is a fast-path hint only, checked before paying for
+// Open - see replayCheckAndAccept for the acceptance decision that
+// actually matters under concurrency.
+func (p *peerCryptoState) replayCheck(counter uint64) bool {
+	p.spinLock()
+	defer p.spinUnlock()
+
+	if counter+replayWindowBits <= p.high {
+		return false
+	}
+	if counter <= p.high {
+		word, bit := replayWindowIndex(p.high, counter)
+		if p.bitmap[word]&(1<<bit) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// replayCheckAndAccept re-validates counter against the window and, if
+// still acceptable, shifts the window and marks the bit seen - all under
+// one spinlock acquisition. This must run immediately after Open succeeds:
+// doing the check and the set in the same critical section is what
+// prevents two concurrent packets carrying the same counter from both
+// passing (replayCheck alone would let both through, since it never sets
+// the bit).
+func (p *peerCryptoState) replayCheckAndAccept(counter uint64) bool {
+	p.spinLock()
+	defer p.spinUnlock()
+
+	if counter+replayWindowBits <= p.high {
+		return false
+	}
+	if counter <= p.high {
+		word, bit := replayWindowIndex(p.high, counter)
+		if p.bitmap[word]&(1<<bit) != 0 {
+			return false
+		}
+	}
+
+	if counter > p.high {
+		shift := counter - p.high
+		if shift >= replayWindowBits {
+			for i := range p.bitmap {
+				p.bitmap[i] = 0
+			}
+		} else {
+			shiftWindow(&p.bitmap, shift)
+		}
+		p.high = counter
+	}
+
+	word, bit := replayWindowIndex(p.high, counter)
+	p.bitmap[word] |= 1 << bit
+	return true
+}
+
+// replayWindowIndex maps counter's position relative to high into a
+// (word, bit) pair in the rolling bitmap.
+func replayWindowIndex(high, counter uint64) (word, bit uint64) {
+	offset := high - counter
+	idx := offset % replayWindowBits
+	return idx / 64, idx % 64
+}
+
+// shiftWindow shifts the bitmap left by n bits (n < replayWindowBits),
+// dropping the oldest n counters out of the window.
+func shiftWindow(bitmap *[replayWindowWords]uint64, n uint64) {
+	wordShift := n / 64
+	bitShift := n % 64
+
+	for i := replayWindowWords - 1; i >= 0; i-- {
+		var v uint64
+		src := i - int(wordShift)
+		if src >= 0 {
+			v = bitmap[src]
+			if bitShift != 0 {
+				v <<= bitShift
+				if src-1 >= 0 {
+					v |= bitmap[src-1] >> (64 - bitShift)
+				}
+			}
+		}
+		bitmap[i] = v
+	}
+}
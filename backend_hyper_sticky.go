@@ -0,0 +1,148 @@
+// +build ignore
+
+package main
+
+import (
+	"net"
+	"sync"
+)
+
+// ============================================================================
+// Sticky-socket source-address preservation for HyperMultiDial winners
+// ============================================================================
+//
+// When HyperMultiDial/HyperDialMulti pick a winning path, pin subsequent
+// sends to that path's egress (local IP + interface). This survives
+// multi-homed hosts, VPN-on-VPN setups, and temporary default-route flaps
+// that would otherwise let the kernel silently reroute traffic and break
+// the peer's NAT binding. On Linux this is enforced for UDP connections
+// with a PKTINFO-carrying sendmsg; TCP connections, and every connection
+// elsewhere, are written with a plain Write that follows the kernel's
+// current routing.
+
+// StickyConn wraps a winning connection together with the local egress it
+// was dialed from, so writes can be pinned to that egress even if the
+// kernel's routing table changes underneath the process.
+type StickyConn struct {
+	net.Conn
+	localAddr net.Addr
+	ifIndex   int
+}
+
+// pinConn captures conn's local egress (address + interface index) and
+// returns a StickyConn that pins future writes to it.
+func pinConn(conn net.Conn) *StickyConn {
+	local := conn.LocalAddr()
+	ifIndex := ifIndexForLocalAddr(local)
+	return &StickyConn{Conn: conn, localAddr: local, ifIndex: ifIndex}
+}
+
+// ifIndexForLocalAddr finds the interface whose address matches local, or
+// 0 if none is found (the platform-specific Write path then falls back to
+// the default route).
+func ifIndexForLocalAddr(local net.Addr) int {
+	host, _, err := net.SplitHostPort(local.String())
+	if err != nil {
+		host = local.String()
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return 0
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return 0
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if ok && ipNet.IP.Equal(ip) {
+				return iface.Index
+			}
+		}
+	}
+	return 0
+}
+
+// Write pins the send to the captured egress. Implementation is
+// platform-specific: see backend_hyper_sticky_linux.go and
+// backend_hyper_sticky_other.go.
+func (s *StickyConn) Write(b []byte) (int, error) {
+	return s.writePinned(b)
+}
+
+// ----------------------------------------------------------------------------
+// JitterAwareRouter integration: prefer an already-pinned route
+// ----------------------------------------------------------------------------
+
+// pinnedRoutes holds the StickyConn captured for each route id that has
+// won a HyperMultiDial race, alongside the RouteMetrics already tracked
+// in JitterAwareRouter.routes.
+var (
+	pinnedRoutesMu sync.RWMutex
+	pinnedRoutes   = make(map[string]*StickyConn)
+)
+
+// HyperPinWinner captures conn's egress and stores it for routeID so a
+// later SelectBestRoute call can prefer the still-pinned route over a
+// freshly dialed one even if the new one benchmarks slightly faster.
+func HyperPinWinner(routeID string, conn net.Conn) *StickyConn {
+	sticky := pinConn(conn)
+	pinnedRoutesMu.Lock()
+	pinnedRoutes[routeID] = sticky
+	pinnedRoutesMu.Unlock()
+	return sticky
+}
+
+// PinnedConn returns the sticky connection pinned for routeID, if any.
+func PinnedConn(routeID string) (*StickyConn, bool) {
+	pinnedRoutesMu.RLock()
+	defer pinnedRoutesMu.RUnlock()
+	conn, ok := pinnedRoutes[routeID]
+	return conn, ok
+}
+
+// stickyPreferenceMargin is the factor by which a fresh route's score
+// (higher is better) must exceed a pinned route's before SelectBestRoute
+// abandons the pinned one.
+const stickyPreferenceMargin = 1.15
+
+// SelectBestRoute picks the route with the best jitter/latency/loss score,
+// but keeps a currently pinned route unless a challenger beats it by more
+// than stickyPreferenceMargin, to avoid needlessly re-pinning on noise.
+func (r *JitterAwareRouter) SelectBestRoute() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var bestRoute string
+	var bestScore float64 = -1
+	for routeID, metric := range r.routes {
+		score := 1.0 / (float64(metric.latency) + float64(metric.jitter)*2 + metric.packetLoss*1000)
+		if score > bestScore {
+			bestScore = score
+			bestRoute = routeID
+		}
+	}
+
+	pinnedRoutesMu.RLock()
+	defer pinnedRoutesMu.RUnlock()
+	for routeID := range pinnedRoutes {
+		if routeID == bestRoute {
+			continue
+		}
+		metric, ok := r.routes[routeID]
+		if !ok {
+			continue
+		}
+		pinnedScore := 1.0 / (float64(metric.latency) + float64(metric.jitter)*2 + metric.packetLoss*1000)
+		if bestScore < pinnedScore*stickyPreferenceMargin {
+			return routeID
+		}
+	}
+	return bestRoute
+}
@@ -0,0 +1,91 @@
+// +build ignore
+
+package main
+
+import (
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// writePinned sends b on the underlying connection, attaching an
+// IP_PKTINFO/IPV6_PKTINFO control message for s.ifIndex so the kernel
+// can't silently re-route the send out a different interface after a
+// default-route flap. Falls back to a plain Write if the connection
+// isn't a UDP socket or no interface was captured.
+func (s *StickyConn) writePinned(b []byte) (int, error) {
+	if s.ifIndex == 0 {
+		return s.Conn.Write(b)
+	}
+
+	udpConn, isUDP := s.Conn.(*net.UDPConn)
+	if isUDP {
+		return s.writePinnedUDP(udpConn, b)
+	}
+	// TCP can't attach per-packet PKTINFO, and the connection is not
+	// bound to the captured interface, so TCP writes are plain Writes
+	// that follow the kernel's current routing.
+	return s.Conn.Write(b)
+}
+
+func (s *StickyConn) writePinnedUDP(conn *net.UDPConn, b []byte) (int, error) {
+	remote, ok := conn.RemoteAddr().(*net.UDPAddr)
+	if !ok {
+		return conn.Write(b)
+	}
+	sa, err := udpAddrToSockaddr(remote)
+	if err != nil {
+		return conn.Write(b)
+	}
+
+	oob := pktinfoCmsg(s.ifIndex, remote.IP.To4() == nil)
+
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return conn.Write(b)
+	}
+
+	var n int
+	var sendErr error
+	ctrlErr := rawConn.Write(func(fd uintptr) bool {
+		sendErr = unix.Sendmsg(int(fd), b, oob, sa, 0)
+		if sendErr == nil {
+			n = len(b)
+		}
+		return true
+	})
+	if ctrlErr != nil {
+		return conn.Write(b)
+	}
+	if sendErr != nil {
+		return 0, sendErr
+	}
+	return n, nil
+}
+
+// pktinfoCmsg builds an IP_PKTINFO (or IPV6_PKTINFO) control message that
+// pins the outgoing packet's egress interface to ifIndex.
+func pktinfoCmsg(ifIndex int, v6 bool) []byte {
+	if v6 {
+		space := unix.CmsgSpace(int(unsafe.Sizeof(unix.Inet6Pktinfo{})))
+		b := make([]byte, space)
+		h := (*unix.Cmsghdr)(unsafe.Pointer(&b[0]))
+		h.Level = unix.IPPROTO_IPV6
+		h.Type = unix.IPV6_PKTINFO
+		h.SetLen(unix.CmsgLen(int(unsafe.Sizeof(unix.Inet6Pktinfo{}))))
+		info := (*unix.Inet6Pktinfo)(unsafe.Pointer(&b[unix.CmsgLen(0)]))
+		info.Ifindex = int32(ifIndex)
+		return b
+	}
+
+	space := unix.CmsgSpace(int(unsafe.Sizeof(unix.Inet4Pktinfo{})))
+	b := make([]byte, space)
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level = unix.IPPROTO_IP
+	h.Type = unix.IP_PKTINFO
+	h.SetLen(unix.CmsgLen(int(unsafe.Sizeof(unix.Inet4Pktinfo{}))))
+	info := (*unix.Inet4Pktinfo)(unsafe.Pointer(&b[unix.CmsgLen(0)]))
+	info.Ifindex = int32(ifIndex)
+	return b
+}
@@ -0,0 +1,12 @@
+// +build ignore
+// +build !linux
+
+package main
+
+// writePinned falls back to a plain Write outside Linux: there is no
+// portable per-packet PKTINFO equivalent, so the egress capture in
+// StickyConn is advisory only (useful for SelectBestRoute's preference,
+// not enforced on the wire).
+func (s *StickyConn) writePinned(b []byte) (int, error) {
+	return s.Conn.Write(b)
+}
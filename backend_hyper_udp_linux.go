@@ -0,0 +1,310 @@
+// +build ignore
+
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ============================================================================
+// UDP GSO/GRO Batched Send/Receive (Linux fast path)
+// ============================================================================
+//
+// Coalesces each run of equal-length same-5-tuple packets into a single
+// UDP_SEGMENT (GSO) datagram on transmit, and drains the socket with
+// recvmmsg plus UDP_GRO on receive, so a 16-32 packet batch of equal-size
+// packets costs one syscall instead of one per packet. Kernel support is
+// probed once at startup and cached; hosts without
+// GSO/GRO fall back to sendmmsg/recvmmsg, which is still one syscall per
+// batch.
+
+const udpGSOMaxSegments = MaxBatchSize
+
+var (
+	udpGSOProbeOnce sync.Once
+	udpGSOSupported bool
+	udpGROProbeOnce sync.Once
+	udpGROSupported bool
+)
+
+// probeUDPGSO attempts setsockopt(UDP_SEGMENT) on a throwaway socket and
+// caches whether the kernel accepted it.
+func probeUDPGSO() bool {
+	udpGSOProbeOnce.Do(func() {
+		fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+		if err != nil {
+			return
+		}
+		defer unix.Close(fd)
+		udpGSOSupported = unix.SetsockoptInt(fd, unix.SOL_UDP, unix.UDP_SEGMENT, 1) == nil
+	})
+	return udpGSOSupported
+}
+
+// probeUDPGRO attempts setsockopt(UDP_GRO) on a throwaway socket and caches
+// whether the kernel accepted it.
+func probeUDPGRO() bool {
+	udpGROProbeOnce.Do(func() {
+		fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+		if err != nil {
+			return
+		}
+		defer unix.Close(fd)
+		udpGROSupported = unix.SetsockoptInt(fd, unix.SOL_UDP, unix.UDP_GRO, 1) == nil
+	})
+	return udpGROSupported
+}
+
+// cmsgUDPSegment builds a control message carrying a UDP_SEGMENT (GSO)
+// segment size, following the cmsghdr layout used by unix.UnixRights.
+func cmsgUDPSegment(segSize int) []byte {
+	space := unix.CmsgSpace(2)
+	b := make([]byte, space)
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level = unix.SOL_UDP
+	h.Type = unix.UDP_SEGMENT
+	h.SetLen(unix.CmsgLen(2))
+	binary.NativeEndian.PutUint16(b[unix.CmsgLen(0):], uint16(segSize))
+	return b
+}
+
+// HyperBatchSendUDP hands a batch of packets headed to dst to the kernel in
+// as few syscalls as possible: one GSO sendmsg per run of equal-length
+// packets when UDP_SEGMENT is available, one sendmmsg otherwise.
+func HyperBatchSendUDP(conn *net.UDPConn, dst *net.UDPAddr, packets [][]byte) error {
+	if len(packets) == 0 {
+		return nil
+	}
+	if probeUDPGSO() {
+		if err := sendUDPGSO(conn, dst, packets); err == nil {
+			return nil
+		}
+		// Kernel accepted the probe but this send failed (e.g. oversized
+		// segment) - fall back rather than dropping the batch.
+	}
+	return sendUDPMMsg(conn, dst, packets)
+}
+
+// sendUDPGSO ships packets with as few sendmsg calls as GSO allows. GSO only
+// coalesces a run of *equal-length* datagrams into one UDP_SEGMENT buffer
+// (the kernel re-splits on transmit using that fixed segment size), so
+// padding a short packet up to the batch max would deliver trailing
+// garbage to the peer. Instead, split the batch into maximal runs of equal
+// length and send each run as its own GSO datagram (or a plain sendmsg for
+// a run of one).
+func sendUDPGSO(conn *net.UDPConn, dst *net.UDPAddr, packets [][]byte) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	sa, err := udpAddrToSockaddr(dst)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(packets); {
+		j := i + 1
+		for j < len(packets) && len(packets[j]) == len(packets[i]) {
+			j++
+		}
+		if err := sendUDPGSORun(rawConn, sa, packets[i:j]); err != nil {
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
+// sendUDPGSORun sends one run of equal-length packets as a single sendmsg,
+// attaching a UDP_SEGMENT control message only when there's more than one
+// packet to coalesce.
+func sendUDPGSORun(rawConn syscall.RawConn, sa unix.Sockaddr, run [][]byte) error {
+	segSize := len(run[0])
+	payload := make([]byte, 0, segSize*len(run))
+	for _, p := range run {
+		payload = append(payload, p...)
+	}
+
+	var oob []byte
+	if len(run) > 1 {
+		oob = cmsgUDPSegment(segSize)
+	}
+
+	var sendErr error
+	ctrlErr := rawConn.Write(func(fd uintptr) bool {
+		sendErr = unix.Sendmsg(int(fd), payload, oob, sa, 0)
+		return true
+	})
+	if ctrlErr != nil {
+		return ctrlErr
+	}
+	return sendErr
+}
+
+// sendUDPMMsg ships a batch of packets in one sendmmsg syscall.
+func sendUDPMMsg(conn *net.UDPConn, dst *net.UDPAddr, packets [][]byte) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	sa, err := udpAddrToSockaddr(dst)
+	if err != nil {
+		return err
+	}
+
+	msgs := make([]unix.Mmsghdr, len(packets))
+	iovecs := make([]unix.Iovec, len(packets))
+	rsa, salen, err := sockaddrToRaw(sa)
+	if err != nil {
+		return err
+	}
+
+	for i, p := range packets {
+		iovecs[i].Base = &p[0]
+		iovecs[i].SetLen(len(p))
+		msgs[i].Hdr.Iov = &iovecs[i]
+		msgs[i].Hdr.Iovlen = 1
+		msgs[i].Hdr.Name = (*byte)(unsafe.Pointer(rsa))
+		msgs[i].Hdr.Namelen = salen
+	}
+
+	var sendErr error
+	ctrlErr := rawConn.Write(func(fd uintptr) bool {
+		_, sendErr = unix.SendmmsgFlags(int(fd), msgs, 0)
+		return true
+	})
+	if ctrlErr != nil {
+		return ctrlErr
+	}
+	return sendErr
+}
+
+// udpRecvBatchSize is how many messages recvmmsg drains in one syscall.
+const udpRecvBatchSize = MaxBatchSize
+
+// HyperBatchRecvUDP drains up to udpRecvBatchSize datagrams from conn with a
+// single recvmmsg syscall, reassembling any UDP_GRO-coalesced super-buffer
+// back into its constituent packets using the GRO segment-size cmsg.
+func HyperBatchRecvUDP(conn *net.UDPConn) ([][]byte, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	if probeUDPGRO() {
+		rawConn.Control(func(fd uintptr) {
+			unix.SetsockoptInt(int(fd), unix.SOL_UDP, unix.UDP_GRO, 1)
+		})
+	}
+
+	bufs := make([][]byte, udpRecvBatchSize)
+	iovecs := make([]unix.Iovec, udpRecvBatchSize)
+	oobs := make([][]byte, udpRecvBatchSize)
+	msgs := make([]unix.Mmsghdr, udpRecvBatchSize)
+	for i := range bufs {
+		bufs[i] = make([]byte, 65535)
+		iovecs[i].Base = &bufs[i][0]
+		iovecs[i].SetLen(len(bufs[i]))
+		oobs[i] = make([]byte, unix.CmsgSpace(2))
+		msgs[i].Hdr.Iov = &iovecs[i]
+		msgs[i].Hdr.Iovlen = 1
+		msgs[i].Hdr.Control = &oobs[i][0]
+		msgs[i].Hdr.SetControllen(len(oobs[i]))
+	}
+
+	var n int
+	var recvErr error
+	ctrlErr := rawConn.Read(func(fd uintptr) bool {
+		n, recvErr = unix.RecvmmsgFlags(int(fd), msgs, 0, nil)
+		return true
+	})
+	if ctrlErr != nil {
+		return nil, ctrlErr
+	}
+	if recvErr != nil {
+		return nil, recvErr
+	}
+
+	out := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		got := bufs[i][:msgs[i].Len]
+		segSize := parseUDPGROSegmentSize(oobs[i][:msgs[i].Hdr.Controllen])
+		if segSize <= 0 || segSize >= len(got) {
+			out = append(out, got)
+			continue
+		}
+		for off := 0; off < len(got); off += segSize {
+			end := off + segSize
+			if end > len(got) {
+				end = len(got)
+			}
+			out = append(out, got[off:end])
+		}
+	}
+	return out, nil
+}
+
+// parseUDPGROSegmentSize extracts the UDP_GRO segment size from a
+// recvmsg control buffer, or 0 if the kernel didn't attach one.
+func parseUDPGROSegmentSize(oob []byte) int {
+	scms, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for _, scm := range scms {
+		if scm.Header.Level == unix.SOL_UDP && scm.Header.Type == unix.UDP_GRO && len(scm.Data) >= 2 {
+			return int(binary.NativeEndian.Uint16(scm.Data))
+		}
+	}
+	return 0
+}
+
+func udpAddrToSockaddr(addr *net.UDPAddr) (unix.Sockaddr, error) {
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		var sa unix.SockaddrInet4
+		sa.Port = addr.Port
+		copy(sa.Addr[:], ip4)
+		return &sa, nil
+	}
+	var sa unix.SockaddrInet6
+	sa.Port = addr.Port
+	copy(sa.Addr[:], addr.IP.To16())
+	return &sa, nil
+}
+
+// sockaddrToRaw renders a unix.Sockaddr into the raw (pointer, length) pair
+// sendmmsg's Mmsghdr needs directly, since unix.Sendmsg does this
+// conversion internally but SendmmsgFlags does not.
+func sockaddrToRaw(sa unix.Sockaddr) (*unix.RawSockaddrAny, uint32, error) {
+	ptr, n, err := sockaddrPointerAndLen(sa)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ptr, n, nil
+}
+
+func sockaddrPointerAndLen(sa unix.Sockaddr) (*unix.RawSockaddrAny, uint32, error) {
+	switch s := sa.(type) {
+	case *unix.SockaddrInet4:
+		var raw unix.RawSockaddrInet4
+		raw.Family = unix.AF_INET
+		raw.Port[0] = byte(s.Port >> 8)
+		raw.Port[1] = byte(s.Port)
+		copy(raw.Addr[:], s.Addr[:])
+		return (*unix.RawSockaddrAny)(unsafe.Pointer(&raw)), unix.SizeofSockaddrInet4, nil
+	case *unix.SockaddrInet6:
+		var raw unix.RawSockaddrInet6
+		raw.Family = unix.AF_INET6
+		raw.Port[0] = byte(s.Port >> 8)
+		raw.Port[1] = byte(s.Port)
+		copy(raw.Addr[:], s.Addr[:])
+		return (*unix.RawSockaddrAny)(unsafe.Pointer(&raw)), unix.SizeofSockaddrInet6, nil
+	default:
+		return nil, 0, unix.EAFNOSUPPORT
+	}
+}
@@ -0,0 +1,36 @@
+// +build ignore
+// +build !linux
+
+package main
+
+import "net"
+
+// ============================================================================
+// UDP GSO/GRO Batched Send/Receive - non-Linux fallback
+// ============================================================================
+//
+// UDP_SEGMENT/UDP_GRO and recvmmsg are Linux-only, so elsewhere we fall back
+// to plain per-packet sendto/ReadFrom. Same API as the Linux fast path so
+// callers don't need a build-tag switch of their own.
+
+// HyperBatchSendUDP sends each packet in the batch with its own WriteTo
+// call; there is no GSO/sendmmsg fast path outside Linux.
+func HyperBatchSendUDP(conn *net.UDPConn, dst *net.UDPAddr, packets [][]byte) error {
+	for _, p := range packets {
+		if _, err := conn.WriteTo(p, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HyperBatchRecvUDP reads a single datagram per call; there is no
+// recvmmsg/GRO fast path outside Linux.
+func HyperBatchRecvUDP(conn *net.UDPConn) ([][]byte, error) {
+	buf := make([]byte, 65535)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{buf[:n]}, nil
+}
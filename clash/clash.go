@@ -0,0 +1,432 @@
+// Package clash converts a Clash/Clash.Meta YAML config's proxies and
+// proxy-groups into Xray-core outbounds and routing, reusing the outbound
+// shape already defined by the subscription package so both importers
+// hand the runner the same JSON structure.
+package clash
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/lhear/SimpleXray/subscription"
+)
+
+// XrayConfig is the minimal slice of an Xray-core config this converter
+// produces: the converted outbounds plus any balancer/routing rules
+// derived from Clash's proxy-groups.
+type XrayConfig struct {
+	Outbounds []subscription.Outbound `json:"outbounds"`
+	Routing   *Routing                `json:"routing,omitempty"`
+}
+
+// Routing mirrors the small part of Xray's routing config that a Clash
+// proxy-group maps onto: a balancer per select/url-test/fallback group,
+// plus a rule sending unmatched traffic into it.
+type Routing struct {
+	Balancers []Balancer `json:"balancers,omitempty"`
+	Rules     []Rule     `json:"rules,omitempty"`
+}
+
+type Balancer struct {
+	Tag       string   `json:"tag"`
+	Selectors []string `json:"selector"`
+	Strategy  Strategy `json:"strategy"`
+}
+
+type Strategy struct {
+	Type string `json:"type"`
+}
+
+type Rule struct {
+	Type        string `json:"type"`
+	BalancerTag string `json:"balancerTag"`
+}
+
+// clashConfig is the subset of a Clash/Clash.Meta document this package
+// understands; unknown top-level keys are ignored.
+type clashConfig struct {
+	Proxies     []map[string]interface{} `yaml:"proxies"`
+	ProxyGroups []clashProxyGroup        `yaml:"proxy-groups"`
+}
+
+type clashProxyGroup struct {
+	Name    string   `yaml:"name"`
+	Type    string   `yaml:"type"`
+	Proxies []string `yaml:"proxies"`
+}
+
+// UnsupportedEntry records a proxy or proxy-group Clash entry this
+// converter couldn't map to an Xray equivalent, along with why.
+type UnsupportedEntry struct {
+	Name   string
+	Reason string
+}
+
+// ConvertClashYAML reads a Clash/Clash.Meta YAML document's proxies and
+// proxy-groups and returns the equivalent Xray outbounds/routing. Entries
+// it can't map are collected into unsupported rather than failing the
+// whole document.
+func ConvertClashYAML(data []byte) (*XrayConfig, []UnsupportedEntry, error) {
+	var cfg clashConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, nil, fmt.Errorf("clash: invalid yaml: %w", err)
+	}
+
+	cfgOut := &XrayConfig{}
+	var unsupported []UnsupportedEntry
+	tags := make(map[string]bool)
+
+	for _, proxy := range cfg.Proxies {
+		name, _ := proxy["name"].(string)
+		ob, err := convertProxy(proxy)
+		if err != nil {
+			unsupported = append(unsupported, UnsupportedEntry{Name: name, Reason: err.Error()})
+			continue
+		}
+		cfgOut.Outbounds = append(cfgOut.Outbounds, ob)
+		tags[ob.Tag] = true
+	}
+
+	for _, group := range cfg.ProxyGroups {
+		balancer, rule, err := convertProxyGroup(group, tags)
+		if err != nil {
+			unsupported = append(unsupported, UnsupportedEntry{Name: group.Name, Reason: err.Error()})
+			continue
+		}
+		if cfgOut.Routing == nil {
+			cfgOut.Routing = &Routing{}
+		}
+		cfgOut.Routing.Balancers = append(cfgOut.Routing.Balancers, *balancer)
+		cfgOut.Routing.Rules = append(cfgOut.Routing.Rules, *rule)
+	}
+
+	return cfgOut, unsupported, nil
+}
+
+// convertProxy maps one `proxies:` entry to an Xray outbound based on its
+// `type` field.
+func convertProxy(p map[string]interface{}) (subscription.Outbound, error) {
+	typ, _ := p["type"].(string)
+	name, _ := p["name"].(string)
+	switch typ {
+	case "vmess":
+		return convertVMessProxy(name, p)
+	case "vless":
+		return convertVLESSProxy(name, p)
+	case "trojan":
+		return convertTrojanProxy(name, p)
+	case "ss":
+		return convertShadowsocksProxy(name, p)
+	case "ss2022", "shadowsocks2022":
+		return convertShadowsocks2022Proxy(name, p)
+	case "socks", "socks5":
+		return convertSocksProxy(name, p)
+	case "http":
+		return convertHTTPProxy(name, p)
+	default:
+		return subscription.Outbound{}, fmt.Errorf("unsupported proxy type %q", typ)
+	}
+}
+
+func convertVMessProxy(name string, p map[string]interface{}) (subscription.Outbound, error) {
+	server, port, err := serverPort(p)
+	if err != nil {
+		return subscription.Outbound{}, err
+	}
+	uuid, _ := p["uuid"].(string)
+	if uuid == "" {
+		return subscription.Outbound{}, fmt.Errorf("vmess: missing uuid")
+	}
+	alterID := toInt(p["alterId"])
+	cipher := toStr(p["cipher"], "auto")
+
+	settings, _ := json.Marshal(map[string]interface{}{
+		"vnext": []map[string]interface{}{{
+			"address": server,
+			"port":    port,
+			"users": []map[string]interface{}{{
+				"id":       uuid,
+				"alterId":  alterID,
+				"security": cipher,
+			}},
+		}},
+	})
+
+	return subscription.Outbound{
+		Tag:            name,
+		Protocol:       "vmess",
+		Settings:       settings,
+		StreamSettings: convertStreamSettings(p, server),
+	}, nil
+}
+
+func convertVLESSProxy(name string, p map[string]interface{}) (subscription.Outbound, error) {
+	server, port, err := serverPort(p)
+	if err != nil {
+		return subscription.Outbound{}, err
+	}
+	uuid, _ := p["uuid"].(string)
+	if uuid == "" {
+		return subscription.Outbound{}, fmt.Errorf("vless: missing uuid")
+	}
+
+	settings, _ := json.Marshal(map[string]interface{}{
+		"vnext": []map[string]interface{}{{
+			"address": server,
+			"port":    port,
+			"users": []map[string]interface{}{{
+				"id":         uuid,
+				"encryption": "none",
+				"flow":       toStr(p["flow"], ""),
+			}},
+		}},
+	})
+
+	stream := convertStreamSettings(p, server)
+	if reality, ok := p["reality-opts"].(map[string]interface{}); ok {
+		stream.Security = "reality"
+		stream.RealitySettings = &subscription.RealitySettings{
+			ServerName:  toStr(p["servername"], server),
+			Fingerprint: toStr(p["client-fingerprint"], ""),
+			PublicKey:   toStr(reality["public-key"], ""),
+			ShortId:     toStr(reality["short-id"], ""),
+		}
+	}
+
+	return subscription.Outbound{
+		Tag:            name,
+		Protocol:       "vless",
+		Settings:       settings,
+		StreamSettings: stream,
+	}, nil
+}
+
+func convertTrojanProxy(name string, p map[string]interface{}) (subscription.Outbound, error) {
+	server, port, err := serverPort(p)
+	if err != nil {
+		return subscription.Outbound{}, err
+	}
+	password, _ := p["password"].(string)
+	if password == "" {
+		return subscription.Outbound{}, fmt.Errorf("trojan: missing password")
+	}
+
+	settings, _ := json.Marshal(map[string]interface{}{
+		"servers": []map[string]interface{}{{
+			"address":  server,
+			"port":     port,
+			"password": password,
+		}},
+	})
+
+	stream := convertStreamSettings(p, server)
+	if stream.Security == "" {
+		stream.Security = "tls"
+	}
+	return subscription.Outbound{
+		Tag:            name,
+		Protocol:       "trojan",
+		Settings:       settings,
+		StreamSettings: stream,
+	}, nil
+}
+
+func convertShadowsocksProxy(name string, p map[string]interface{}) (subscription.Outbound, error) {
+	server, port, err := serverPort(p)
+	if err != nil {
+		return subscription.Outbound{}, err
+	}
+	cipher, _ := p["cipher"].(string)
+	password, _ := p["password"].(string)
+	if cipher == "" || password == "" {
+		return subscription.Outbound{}, fmt.Errorf("ss: missing cipher/password")
+	}
+
+	server_ := map[string]interface{}{
+		"address":  server,
+		"port":     port,
+		"method":   cipher,
+		"password": password,
+	}
+	if plugin, _ := p["plugin"].(string); plugin != "" {
+		pluginOpts, _ := p["plugin-opts"].(map[string]interface{})
+		server_["pluginName"] = plugin
+		server_["pluginArgs"] = pluginOpts
+	}
+
+	settings, _ := json.Marshal(map[string]interface{}{
+		"servers": []map[string]interface{}{server_},
+	})
+
+	return subscription.Outbound{
+		Tag:      name,
+		Protocol: "shadowsocks",
+		Settings: settings,
+	}, nil
+}
+
+func convertShadowsocks2022Proxy(name string, p map[string]interface{}) (subscription.Outbound, error) {
+	ob, err := convertShadowsocksProxy(name, p)
+	if err != nil {
+		return subscription.Outbound{}, fmt.Errorf("ss2022: %w", err)
+	}
+	return ob, nil
+}
+
+func convertSocksProxy(name string, p map[string]interface{}) (subscription.Outbound, error) {
+	server, port, err := serverPort(p)
+	if err != nil {
+		return subscription.Outbound{}, err
+	}
+	user := map[string]interface{}{}
+	if username, _ := p["username"].(string); username != "" {
+		user["user"] = username
+		user["pass"], _ = p["password"].(string)
+	}
+	serverEntry := map[string]interface{}{"address": server, "port": port}
+	if len(user) > 0 {
+		serverEntry["users"] = []map[string]interface{}{user}
+	}
+	settings, _ := json.Marshal(map[string]interface{}{
+		"servers": []map[string]interface{}{serverEntry},
+	})
+	return subscription.Outbound{Tag: name, Protocol: "socks", Settings: settings}, nil
+}
+
+func convertHTTPProxy(name string, p map[string]interface{}) (subscription.Outbound, error) {
+	server, port, err := serverPort(p)
+	if err != nil {
+		return subscription.Outbound{}, err
+	}
+	serverEntry := map[string]interface{}{"address": server, "port": port}
+	if username, _ := p["username"].(string); username != "" {
+		serverEntry["users"] = []map[string]interface{}{{
+			"user": username,
+			"pass": toStr(p["password"], ""),
+		}}
+	}
+	settings, _ := json.Marshal(map[string]interface{}{
+		"servers": []map[string]interface{}{serverEntry},
+	})
+	return subscription.Outbound{Tag: name, Protocol: "http", Settings: settings}, nil
+}
+
+// convertProxyGroup maps a select/url-test/fallback proxy-group to an
+// Xray balancer plus a routing rule that sends unmatched traffic to it.
+// Other group types (relay, load-balance with unclear Xray equivalents)
+// are reported as unsupported.
+func convertProxyGroup(g clashProxyGroup, knownTags map[string]bool) (*Balancer, *Rule, error) {
+	switch g.Type {
+	case "select", "url-test", "fallback":
+	default:
+		return nil, nil, fmt.Errorf("unsupported proxy-group type %q", g.Type)
+	}
+
+	var selectors []string
+	for _, name := range g.Proxies {
+		if knownTags[name] {
+			selectors = append(selectors, name)
+		}
+	}
+	if len(selectors) == 0 {
+		return nil, nil, fmt.Errorf("no convertible members in group %q", g.Name)
+	}
+
+	strategy := "random"
+	if g.Type == "url-test" || g.Type == "fallback" {
+		strategy = "leastPing"
+	}
+
+	balancer := &Balancer{Tag: g.Name, Selectors: selectors, Strategy: Strategy{Type: strategy}}
+	rule := &Rule{Type: "field", BalancerTag: g.Name}
+	return balancer, rule, nil
+}
+
+// ----------------------------------------------------------------------------
+// Shared field helpers
+// ----------------------------------------------------------------------------
+
+func serverPort(p map[string]interface{}) (string, int, error) {
+	server, _ := p["server"].(string)
+	if server == "" {
+		return "", 0, fmt.Errorf("missing server")
+	}
+	port := toInt(p["port"])
+	if port == 0 {
+		return "", 0, fmt.Errorf("missing port")
+	}
+	return server, port, nil
+}
+
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	case string:
+		var out int
+		fmt.Sscanf(n, "%d", &out)
+		return out
+	default:
+		return 0
+	}
+}
+
+func toStr(v interface{}, def string) string {
+	if s, ok := v.(string); ok && s != "" {
+		return s
+	}
+	return def
+}
+
+// convertStreamSettings maps Clash's network/tls/ws-opts/grpc-opts fields to
+// Xray's streamSettings shape. Clash's "h2"/"http" network and its h2-opts
+// have no Xray equivalent left to map to - upstream removed the standalone
+// HTTP/2 transport in favor of XHTTP - so a proxy entry using it is passed
+// through with only Network set and no transport-specific settings.
+func convertStreamSettings(p map[string]interface{}, server string) *subscription.StreamSettings {
+	network := toStr(p["network"], "tcp")
+	stream := &subscription.StreamSettings{Network: network}
+
+	if tls, _ := p["tls"].(bool); tls {
+		stream.Security = "tls"
+		stream.TLSSettings = &subscription.TLSSettings{
+			ServerName:    toStr(p["servername"], server),
+			Fingerprint:   toStr(p["client-fingerprint"], ""),
+			AllowInsecure: boolOr(p["skip-cert-verify"], false),
+		}
+	}
+
+	switch network {
+	case "ws":
+		if opts, ok := p["ws-opts"].(map[string]interface{}); ok {
+			ws := &subscription.WSSettings{Path: toStr(opts["path"], "/")}
+			if headers, ok := opts["headers"].(map[string]interface{}); ok {
+				ws.Headers = map[string]string{}
+				for k, v := range headers {
+					ws.Headers[k] = toStr(v, "")
+				}
+			}
+			stream.WSSettings = ws
+		}
+	case "grpc":
+		if opts, ok := p["grpc-opts"].(map[string]interface{}); ok {
+			stream.GRPCSettings = &subscription.GRPCSettings{
+				ServiceName: toStr(opts["grpc-service-name"], ""),
+			}
+		}
+	}
+	return stream
+}
+
+func boolOr(v interface{}, def bool) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return def
+}
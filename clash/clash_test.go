@@ -0,0 +1,107 @@
+package clash
+
+import "testing"
+
+func TestConvertClashYAML(t *testing.T) {
+	yaml := `
+proxies:
+  - name: vmess-node
+    type: vmess
+    server: example.com
+    port: 443
+    uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+    alterId: 0
+    cipher: auto
+    network: ws
+    ws-opts:
+      path: /ws
+      headers:
+        Host: example.com
+    tls: true
+    servername: example.com
+    client-fingerprint: chrome
+  - name: vless-reality-node
+    type: vless
+    server: example.com
+    port: 443
+    uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+    network: tcp
+    flow: xtls-rprx-vision
+    reality-opts:
+      public-key: pubkey
+      short-id: abcd
+    client-fingerprint: chrome
+  - name: trojan-node
+    type: trojan
+    server: example.com
+    port: 443
+    password: secret
+  - name: ss-node
+    type: ss
+    server: example.com
+    port: 8388
+    cipher: aes-256-gcm
+    password: secret
+  - name: unsupported-node
+    type: vmess
+    server: example.com
+proxy-groups:
+  - name: auto
+    type: url-test
+    proxies:
+      - vmess-node
+      - trojan-node
+  - name: bogus-group
+    type: relay
+    proxies:
+      - vmess-node
+`
+
+	cfg, unsupported, err := ConvertClashYAML([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ConvertClashYAML: %v", err)
+	}
+
+	if len(cfg.Outbounds) != 4 {
+		t.Fatalf("got %d outbounds, want 4: %+v", len(cfg.Outbounds), cfg.Outbounds)
+	}
+
+	byTag := make(map[string]int)
+	for i, ob := range cfg.Outbounds {
+		byTag[ob.Tag] = i
+	}
+
+	vless := cfg.Outbounds[byTag["vless-reality-node"]]
+	if vless.StreamSettings == nil || vless.StreamSettings.RealitySettings == nil {
+		t.Fatalf("vless-reality-node missing RealitySettings: %+v", vless.StreamSettings)
+	}
+	if vless.StreamSettings.RealitySettings.PublicKey != "pubkey" {
+		t.Errorf("RealitySettings.PublicKey = %q, want %q", vless.StreamSettings.RealitySettings.PublicKey, "pubkey")
+	}
+
+	vmess := cfg.Outbounds[byTag["vmess-node"]]
+	if vmess.StreamSettings == nil || vmess.StreamSettings.WSSettings == nil || vmess.StreamSettings.WSSettings.Path != "/ws" {
+		t.Errorf("vmess-node ws-opts not translated: %+v", vmess.StreamSettings)
+	}
+	if vmess.StreamSettings == nil || vmess.StreamSettings.TLSSettings == nil || vmess.StreamSettings.TLSSettings.Fingerprint != "chrome" {
+		t.Errorf("vmess-node plain-TLS client-fingerprint not translated: %+v", vmess.StreamSettings)
+	}
+
+	if len(unsupported) != 2 {
+		t.Fatalf("got %d unsupported entries, want 2 (one bad proxy + one bad group): %+v", len(unsupported), unsupported)
+	}
+
+	if cfg.Routing == nil || len(cfg.Routing.Balancers) != 1 {
+		t.Fatalf("expected exactly one balancer from the url-test group, got %+v", cfg.Routing)
+	}
+	if cfg.Routing.Balancers[0].Strategy.Type != "leastPing" {
+		t.Errorf("url-test balancer strategy = %q, want leastPing", cfg.Routing.Balancers[0].Strategy.Type)
+	}
+}
+
+func TestConvertClashYAMLInvalidDocument(t *testing.T) {
+	_, _, err := ConvertClashYAML([]byte("not: [valid"))
+	if err == nil {
+		t.Fatal("expected an error for invalid yaml")
+	}
+}
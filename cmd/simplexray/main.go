@@ -0,0 +1,46 @@
+// Command simplexray is the CLI entry point for SimpleXray's standalone
+// helper subcommands - tools useful for scripting that don't need the full
+// GUI app running.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lhear/SimpleXray/common/uuid"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "uuid":
+		err = runUUID(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "simplexray:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: simplexray uuid <text>")
+}
+
+// runUUID prints the UUID that subscription's VMess/VLESS loaders would
+// derive for text, matching v2rayN's "custom text" id convention.
+func runUUID(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("uuid: expected exactly one argument, got %d", len(args))
+	}
+	fmt.Println(uuid.UUIDFromText(args[0]).String())
+	return nil
+}
@@ -0,0 +1,28 @@
+// Package uuid derives a stable UUID from arbitrary text, matching the
+// "custom text" convention v2rayN and Xray-on-mobile clients use so users
+// can put any string in a VMess/VLESS `id` field instead of a literal
+// UUID.
+package uuid
+
+import (
+	"crypto/md5"
+
+	"github.com/google/uuid"
+)
+
+// UUIDFromText returns text unchanged (as a uuid.UUID) if it already
+// parses as one, and otherwise deterministically derives one by MD5
+// hashing text and forcing the version/variant bits to produce a valid
+// v3-style UUID - the same derivation v2rayN and Xray's mobile clients
+// use for the "custom text" id convention.
+func UUIDFromText(text string) uuid.UUID {
+	if parsed, err := uuid.Parse(text); err == nil {
+		return parsed
+	}
+
+	sum := md5.Sum([]byte(text))
+	sum[6] = (sum[6] & 0x0f) | 0x30 // version 3
+	sum[8] = (sum[8] & 0x3f) | 0x80 // RFC 4122 variant
+	parsed, _ := uuid.FromBytes(sum[:])
+	return parsed
+}
@@ -0,0 +1,61 @@
+package uuid
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestUUIDFromText(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "already a uuid is returned unchanged",
+			text: "c4f9a2e0-1234-4abc-9def-0123456789ab",
+			want: "c4f9a2e0-1234-4abc-9def-0123456789ab",
+		},
+		{
+			name: "uppercase uuid is returned unchanged (lowercased)",
+			text: "C4F9A2E0-1234-4ABC-9DEF-0123456789AB",
+			want: "c4f9a2e0-1234-4abc-9def-0123456789ab",
+		},
+		{
+			name: "arbitrary text is derived deterministically",
+			text: "my-custom-id",
+			want: "", // checked structurally below, not by literal value
+		},
+		{
+			name: "empty text still derives a uuid",
+			text: "",
+			want: "",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := UUIDFromText(tc.text)
+			if tc.want != "" && got.String() != tc.want {
+				t.Fatalf("UUIDFromText(%q) = %s, want %s", tc.text, got, tc.want)
+			}
+			if tc.want == "" {
+				if got.Version() != 3 {
+					t.Fatalf("UUIDFromText(%q) version = %d, want 3", tc.text, got.Version())
+				}
+				if got.Variant() != uuid.RFC4122 {
+					t.Fatalf("UUIDFromText(%q) variant = %v, want RFC4122", tc.text, got.Variant())
+				}
+			}
+		})
+	}
+}
+
+func TestUUIDFromTextIsDeterministic(t *testing.T) {
+	a := UUIDFromText("repeatable-text")
+	b := UUIDFromText("repeatable-text")
+	if a != b {
+		t.Fatalf("UUIDFromText is not deterministic: %s != %s", a, b)
+	}
+}
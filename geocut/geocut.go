@@ -0,0 +1,247 @@
+// Package geocut trims geoip.dat/geosite.dat down to only the
+// country-code/category tags (and @attribute subsets) a given routing
+// config actually references, so mobile/embedded builds can ship a
+// minimized copy instead of the full multi-megabyte file.
+package geocut
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/xtls/xray-core/app/router"
+	"github.com/xtls/xray-core/infra/conf"
+	"github.com/xtls/xray-core/infra/conf/serial"
+)
+
+// CutStats reports, per requested tag, how many rules survived the cut -
+// useful for showing the user what a minimized geo file actually kept.
+type CutStats struct {
+	TagRuleCounts map[string]int
+	TotalEntries  int
+	KeptEntries   int
+}
+
+// CutGeoSite reads the GeoSiteList protobuf at datPath, keeps only the
+// GeoSite entries named in wanted (tag -> requested @attributes; an empty
+// attribute list keeps every domain in that entry), and writes the
+// minimized GeoSiteList to out.
+func CutGeoSite(datPath string, wanted map[string][]string, out io.Writer) (CutStats, error) {
+	raw, err := os.ReadFile(datPath)
+	if err != nil {
+		return CutStats{}, fmt.Errorf("geocut: read %s: %w", datPath, err)
+	}
+
+	var list router.GeoSiteList
+	if err := proto.Unmarshal(raw, &list); err != nil {
+		return CutStats{}, fmt.Errorf("geocut: parse %s: %w", datPath, err)
+	}
+
+	stats := CutStats{TagRuleCounts: make(map[string]int), TotalEntries: len(list.Entry)}
+	var kept []*router.GeoSite
+
+	for _, site := range list.Entry {
+		tag := strings.ToLower(site.CountryCode)
+		attrs, ok := wanted[tag]
+		if !ok {
+			continue
+		}
+
+		domains := site.Domain
+		if len(attrs) > 0 {
+			domains = filterDomainsByAttribute(site.Domain, attrs)
+		}
+		if len(domains) == 0 {
+			continue
+		}
+
+		kept = append(kept, &router.GeoSite{
+			CountryCode: site.CountryCode,
+			Domain:      domains,
+		})
+		stats.TagRuleCounts[tag] = len(domains)
+		stats.KeptEntries++
+	}
+
+	outBytes, err := proto.Marshal(&router.GeoSiteList{Entry: kept})
+	if err != nil {
+		return CutStats{}, fmt.Errorf("geocut: marshal trimmed geosite: %w", err)
+	}
+	if _, err := out.Write(outBytes); err != nil {
+		return CutStats{}, fmt.Errorf("geocut: write trimmed geosite: %w", err)
+	}
+	return stats, nil
+}
+
+// CutGeoIP reads the GeoIPList protobuf at datPath, keeps only the GeoIP
+// entries named in wanted, and writes the minimized GeoIPList to out.
+// GeoIP entries have no @attribute subsets, so the attribute list in
+// wanted is accepted only for API symmetry with CutGeoSite and ignored.
+func CutGeoIP(datPath string, wanted map[string][]string, out io.Writer) (CutStats, error) {
+	raw, err := os.ReadFile(datPath)
+	if err != nil {
+		return CutStats{}, fmt.Errorf("geocut: read %s: %w", datPath, err)
+	}
+
+	var list router.GeoIPList
+	if err := proto.Unmarshal(raw, &list); err != nil {
+		return CutStats{}, fmt.Errorf("geocut: parse %s: %w", datPath, err)
+	}
+
+	stats := CutStats{TagRuleCounts: make(map[string]int), TotalEntries: len(list.Entry)}
+	var kept []*router.GeoIP
+
+	for _, ipList := range list.Entry {
+		tag := strings.ToLower(ipList.CountryCode)
+		if _, ok := wanted[tag]; !ok {
+			continue
+		}
+		kept = append(kept, ipList)
+		stats.TagRuleCounts[tag] = len(ipList.Cidr)
+		stats.KeptEntries++
+	}
+
+	outBytes, err := proto.Marshal(&router.GeoIPList{Entry: kept})
+	if err != nil {
+		return CutStats{}, fmt.Errorf("geocut: marshal trimmed geoip: %w", err)
+	}
+	if _, err := out.Write(outBytes); err != nil {
+		return CutStats{}, fmt.Errorf("geocut: write trimmed geoip: %w", err)
+	}
+	return stats, nil
+}
+
+// filterDomainsByAttribute keeps only domains tagged with at least one of
+// the requested @attribute names (e.g. "geosite:category-ads-all@cn").
+func filterDomainsByAttribute(domains []*router.Domain, wantedAttrs []string) []*router.Domain {
+	want := make(map[string]bool, len(wantedAttrs))
+	for _, a := range wantedAttrs {
+		want[strings.ToLower(a)] = true
+	}
+
+	var kept []*router.Domain
+	for _, d := range domains {
+		for _, attr := range d.Attribute {
+			if want[strings.ToLower(attr.Key)] {
+				kept = append(kept, d)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// ScanConfig parses an Xray routing config in the given format ("json",
+// "yaml", or "toml") and collects every geosite:/geoip: reference (with any
+// @attribute suffix split out) from its routing rules, so callers can build
+// the `wanted` map for CutGeoSite/CutGeoIP without hand-maintaining it.
+//
+// This has to work from the pre-build *conf.Config rather than an already
+// built *core.Config: Build() expands every "geosite:" domain reference
+// into its concrete domain list, so by the time a config is built the
+// literal tag is gone from rule.Domain - there's nothing left to scan for.
+// ("geoip:" happens to survive Build() as router.GeoIP.CountryCode, but we
+// scan it from the same raw rule here for one consistent code path.)
+func ScanConfig(format string, data []byte) (siteTags, ipTags map[string][]string, err error) {
+	siteTags = make(map[string][]string)
+	ipTags = make(map[string][]string)
+
+	routerConfig, err := decodeRouterConfig(format, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("geocut: %w", err)
+	}
+	if routerConfig == nil {
+		return siteTags, ipTags, nil
+	}
+
+	for _, rawRule := range routerConfig.RuleList {
+		var rule struct {
+			Domain  *conf.StringList `json:"domain"`
+			Domains *conf.StringList `json:"domains"`
+			IP      *conf.StringList `json:"ip"`
+		}
+		if err := json.Unmarshal(rawRule, &rule); err != nil {
+			continue
+		}
+		for _, list := range []*conf.StringList{rule.Domain, rule.Domains} {
+			if list == nil {
+				continue
+			}
+			for _, value := range *list {
+				collectDomainRef(value, siteTags)
+			}
+		}
+		if rule.IP != nil {
+			for _, value := range *rule.IP {
+				collectIPRef(value, ipTags)
+			}
+		}
+	}
+	return siteTags, ipTags, nil
+}
+
+// decodeRouterConfig decodes data in the given format into the routing
+// section of a *conf.Config, or nil if the document has none.
+func decodeRouterConfig(format string, data []byte) (*conf.RouterConfig, error) {
+	var jsonConfig *conf.Config
+	var err error
+	switch format {
+	case "json", "":
+		jsonConfig, err = serial.DecodeJSONConfig(bytes.NewReader(data))
+	case "yaml":
+		jsonConfig, err = serial.DecodeYAMLConfig(bytes.NewReader(data))
+	case "toml":
+		jsonConfig, err = serial.DecodeTOMLConfig(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return jsonConfig.RouterConfig, nil
+}
+
+// collectDomainRef parses a single domain rule value like
+// "geosite:category-ads-all@cn" into its tag and optional attribute, and
+// records it in tags.
+func collectDomainRef(value string, tags map[string][]string) {
+	const prefix = "geosite:"
+	if !strings.HasPrefix(value, prefix) {
+		return
+	}
+	rest := strings.TrimPrefix(value, prefix)
+	tag := rest
+	attr := ""
+	if idx := strings.IndexByte(rest, '@'); idx >= 0 {
+		tag = rest[:idx]
+		attr = rest[idx+1:]
+	}
+	tag = strings.ToLower(tag)
+	if attr != "" {
+		tags[tag] = append(tags[tag], attr)
+	} else if _, ok := tags[tag]; !ok {
+		tags[tag] = []string{}
+	}
+}
+
+// collectIPRef parses a single ip rule value like "geoip:!cn" into its
+// tag and records it in tags. GeoIP entries have no @attribute subsets, so
+// this only exists for a shape symmetric with collectDomainRef.
+func collectIPRef(value string, tags map[string][]string) {
+	const prefix = "geoip:"
+	if !strings.HasPrefix(value, prefix) {
+		return
+	}
+	tag := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(value, prefix), "!"))
+	if tag == "" {
+		return
+	}
+	if _, ok := tags[tag]; !ok {
+		tags[tag] = []string{}
+	}
+}
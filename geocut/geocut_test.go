@@ -0,0 +1,186 @@
+package geocut
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/xtls/xray-core/app/router"
+)
+
+func writeGeoSiteDat(t *testing.T, list *router.GeoSiteList) string {
+	t.Helper()
+	raw, err := proto.Marshal(list)
+	if err != nil {
+		t.Fatalf("marshal GeoSiteList: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "geosite.dat")
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatalf("write geosite.dat: %v", err)
+	}
+	return path
+}
+
+func writeGeoIPDat(t *testing.T, list *router.GeoIPList) string {
+	t.Helper()
+	raw, err := proto.Marshal(list)
+	if err != nil {
+		t.Fatalf("marshal GeoIPList: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "geoip.dat")
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatalf("write geoip.dat: %v", err)
+	}
+	return path
+}
+
+func TestCutGeoSite(t *testing.T) {
+	list := &router.GeoSiteList{
+		Entry: []*router.GeoSite{
+			{
+				CountryCode: "CN",
+				Domain: []*router.Domain{
+					{Type: router.Domain_Plain, Value: "example.cn"},
+					{
+						Type:  router.Domain_Plain,
+						Value: "ads.example.cn",
+						Attribute: []*router.Domain_Attribute{
+							{Key: "ads", TypedValue: &router.Domain_Attribute_BoolValue{BoolValue: true}},
+						},
+					},
+				},
+			},
+			{
+				CountryCode: "US",
+				Domain: []*router.Domain{
+					{Type: router.Domain_Plain, Value: "example.us"},
+				},
+			},
+		},
+	}
+	path := writeGeoSiteDat(t, list)
+
+	var out bytes.Buffer
+	stats, err := CutGeoSite(path, map[string][]string{"cn": nil}, &out)
+	if err != nil {
+		t.Fatalf("CutGeoSite: %v", err)
+	}
+	if stats.TotalEntries != 2 || stats.KeptEntries != 1 {
+		t.Fatalf("stats = %+v, want TotalEntries=2 KeptEntries=1", stats)
+	}
+	if stats.TagRuleCounts["cn"] != 2 {
+		t.Errorf("TagRuleCounts[cn] = %d, want 2 (empty attribute list keeps all domains)", stats.TagRuleCounts["cn"])
+	}
+
+	var trimmed router.GeoSiteList
+	if err := proto.Unmarshal(out.Bytes(), &trimmed); err != nil {
+		t.Fatalf("unmarshal trimmed geosite: %v", err)
+	}
+	if len(trimmed.Entry) != 1 || trimmed.Entry[0].CountryCode != "CN" {
+		t.Fatalf("trimmed entries = %+v, want only CN", trimmed.Entry)
+	}
+}
+
+func TestCutGeoSiteByAttribute(t *testing.T) {
+	list := &router.GeoSiteList{
+		Entry: []*router.GeoSite{
+			{
+				CountryCode: "CN",
+				Domain: []*router.Domain{
+					{Type: router.Domain_Plain, Value: "example.cn"},
+					{
+						Type:  router.Domain_Plain,
+						Value: "ads.example.cn",
+						Attribute: []*router.Domain_Attribute{
+							{Key: "ads", TypedValue: &router.Domain_Attribute_BoolValue{BoolValue: true}},
+						},
+					},
+				},
+			},
+		},
+	}
+	path := writeGeoSiteDat(t, list)
+
+	var out bytes.Buffer
+	stats, err := CutGeoSite(path, map[string][]string{"cn": {"ads"}}, &out)
+	if err != nil {
+		t.Fatalf("CutGeoSite: %v", err)
+	}
+	if stats.TagRuleCounts["cn"] != 1 {
+		t.Fatalf("TagRuleCounts[cn] = %d, want 1 (only the @ads domain)", stats.TagRuleCounts["cn"])
+	}
+}
+
+func TestCutGeoIP(t *testing.T) {
+	list := &router.GeoIPList{
+		Entry: []*router.GeoIP{
+			{CountryCode: "CN", Cidr: []*router.CIDR{{Ip: []byte{1, 2, 3, 0}, Prefix: 24}}},
+			{CountryCode: "PRIVATE", Cidr: []*router.CIDR{{Ip: []byte{10, 0, 0, 0}, Prefix: 8}}},
+		},
+	}
+	path := writeGeoIPDat(t, list)
+
+	var out bytes.Buffer
+	stats, err := CutGeoIP(path, map[string][]string{"private": nil}, &out)
+	if err != nil {
+		t.Fatalf("CutGeoIP: %v", err)
+	}
+	if stats.KeptEntries != 1 || stats.TagRuleCounts["private"] != 1 {
+		t.Fatalf("stats = %+v, want one kept private CIDR", stats)
+	}
+}
+
+func TestScanConfigFindsGeositeAndGeoipReferences(t *testing.T) {
+	cfg := []byte(`{
+		"routing": {
+			"rules": [
+				{
+					"type": "field",
+					"domain": ["geosite:cn", "geosite:category-ads-all@ads", "example.com"],
+					"outboundTag": "direct"
+				},
+				{
+					"type": "field",
+					"ip": ["geoip:private", "geoip:!cn"],
+					"outboundTag": "block"
+				}
+			]
+		}
+	}`)
+
+	siteTags, ipTags, err := ScanConfig("json", cfg)
+	if err != nil {
+		t.Fatalf("ScanConfig: %v", err)
+	}
+
+	if _, ok := siteTags["cn"]; !ok {
+		t.Errorf("siteTags missing cn: %+v", siteTags)
+	}
+	attrs, ok := siteTags["category-ads-all"]
+	if !ok || len(attrs) != 1 || attrs[0] != "ads" {
+		t.Errorf("siteTags[category-ads-all] = %+v, want [ads]", attrs)
+	}
+	if len(siteTags) != 2 {
+		t.Errorf("siteTags = %+v, want exactly 2 tags (plain domain must not leak in)", siteTags)
+	}
+
+	if _, ok := ipTags["private"]; !ok {
+		t.Errorf("ipTags missing private: %+v", ipTags)
+	}
+	if _, ok := ipTags["cn"]; !ok {
+		t.Errorf("ipTags missing cn (from the negated geoip:!cn rule): %+v", ipTags)
+	}
+}
+
+func TestScanConfigNoRouting(t *testing.T) {
+	siteTags, ipTags, err := ScanConfig("json", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("ScanConfig: %v", err)
+	}
+	if len(siteTags) != 0 || len(ipTags) != 0 {
+		t.Fatalf("expected empty tag maps for a config with no routing section, got %+v / %+v", siteTags, ipTags)
+	}
+}
@@ -0,0 +1,202 @@
+// Package probe measures per-outbound latency through a running Xray
+// instance without touching the system proxy, by dialing through
+// core.Dial with the outbound tag pinned in the session context so
+// routing is bypassed entirely.
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	xnet "github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+)
+
+// Mode selects how Probe measures an outbound.
+type Mode int
+
+const (
+	// TCPConnect times a raw TCP handshake to opts.Target ("host:port").
+	TCPConnect Mode = iota
+	// HTTPGet times a full HTTP GET to opts.Target, expecting opts.ExpectStatus.
+	HTTPGet
+)
+
+// ProbeOptions configures a single Probe/ProbeAll call.
+type ProbeOptions struct {
+	Mode         Mode
+	Target       string        // "host:port" for TCPConnect, a URL for HTTPGet
+	ExpectStatus int           // HTTPGet only; defaults to 204
+	Timeout      time.Duration // per-attempt timeout; defaults to 5s
+	Repeat       int           // number of attempts; defaults to 1
+}
+
+// ProbeResult summarizes Repeat attempts against one outbound.
+type ProbeResult struct {
+	Min, Avg, Max time.Duration
+	Loss          float64 // fraction of attempts that failed, 0..1
+	RemoteAddr    string
+	Err           error // last error, if every attempt failed
+}
+
+const defaultTimeout = 5 * time.Second
+
+// Probe measures outboundTag's latency per opts, dialing through
+// instance's router with the destination tag forced so the configured
+// routing rules are bypassed.
+func Probe(ctx context.Context, instance *core.Instance, outboundTag string, opts ProbeOptions) (ProbeResult, error) {
+	opts = withDefaults(opts)
+
+	var durations []time.Duration
+	var lastErr error
+	var remoteAddr string
+
+	for i := 0; i < opts.Repeat; i++ {
+		d, addr, err := probeOnce(ctx, instance, outboundTag, opts)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		durations = append(durations, d)
+		if remoteAddr == "" {
+			remoteAddr = addr
+		}
+	}
+
+	result := ProbeResult{RemoteAddr: remoteAddr, Loss: float64(opts.Repeat-len(durations)) / float64(opts.Repeat)}
+	if len(durations) == 0 {
+		result.Err = fmt.Errorf("probe: all %d attempts to %q via %q failed: %w", opts.Repeat, opts.Target, outboundTag, lastErr)
+		return result, result.Err
+	}
+
+	result.Min, result.Max = durations[0], durations[0]
+	var total time.Duration
+	for _, d := range durations {
+		if d < result.Min {
+			result.Min = d
+		}
+		if d > result.Max {
+			result.Max = d
+		}
+		total += d
+	}
+	result.Avg = total / time.Duration(len(durations))
+	return result, nil
+}
+
+// ProbeAll runs Probe for every tag concurrently, bounded by concurrency.
+func ProbeAll(ctx context.Context, instance *core.Instance, tags []string, opts ProbeOptions, concurrency int) map[string]ProbeResult {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	results := make(map[string]ProbeResult, len(tags))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, tag := range tags {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(tag string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res, _ := Probe(ctx, instance, tag, opts)
+			mu.Lock()
+			results[tag] = res
+			mu.Unlock()
+		}(tag)
+	}
+	wg.Wait()
+	return results
+}
+
+func withDefaults(opts ProbeOptions) ProbeOptions {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.Repeat <= 0 {
+		opts.Repeat = 1
+	}
+	if opts.Mode == HTTPGet && opts.ExpectStatus == 0 {
+		opts.ExpectStatus = http.StatusNoContent
+	}
+	return opts
+}
+
+// outboundContext pins outboundTag onto ctx so core.Dial routes through
+// that outbound specifically, bypassing the configured routing rules.
+func outboundContext(ctx context.Context, outboundTag string) context.Context {
+	return session.ContextWithOutbounds(ctx, []*session.Outbound{{Tag: outboundTag}})
+}
+
+func probeOnce(ctx context.Context, instance *core.Instance, outboundTag string, opts ProbeOptions) (time.Duration, string, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	dialCtx = outboundContext(dialCtx, outboundTag)
+
+	switch opts.Mode {
+	case TCPConnect:
+		return probeTCPConnect(dialCtx, instance, opts.Target)
+	case HTTPGet:
+		return probeHTTPGet(dialCtx, instance, opts)
+	default:
+		return 0, "", fmt.Errorf("probe: unknown mode %v", opts.Mode)
+	}
+}
+
+func probeTCPConnect(ctx context.Context, instance *core.Instance, target string) (time.Duration, string, error) {
+	dest, err := xnet.ParseDestination("tcp:" + target)
+	if err != nil {
+		return 0, "", fmt.Errorf("probe: invalid target %q: %w", target, err)
+	}
+
+	start := time.Now()
+	conn, err := core.Dial(ctx, instance, dest)
+	elapsed := time.Since(start)
+	if err != nil {
+		return 0, "", fmt.Errorf("probe: dial %s: %w", target, err)
+	}
+	defer conn.Close()
+	return elapsed, conn.RemoteAddr().String(), nil
+}
+
+func probeHTTPGet(ctx context.Context, instance *core.Instance, opts ProbeOptions) (time.Duration, string, error) {
+	var remoteAddr string
+	transport := &http.Transport{
+		DialContext: func(dialCtx context.Context, network, addr string) (net.Conn, error) {
+			dest, err := xnet.ParseDestination(network + ":" + addr)
+			if err != nil {
+				return nil, err
+			}
+			conn, err := core.Dial(ctx, instance, dest)
+			if err == nil {
+				remoteAddr = conn.RemoteAddr().String()
+			}
+			return conn, err
+		},
+	}
+	client := &http.Client{Transport: transport, Timeout: opts.Timeout}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.Target, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("probe: invalid url %q: %w", opts.Target, err)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		return 0, "", fmt.Errorf("probe: get %s: %w", opts.Target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != opts.ExpectStatus {
+		return 0, "", fmt.Errorf("probe: get %s: expected status %d, got %d", opts.Target, opts.ExpectStatus, resp.StatusCode)
+	}
+	return elapsed, remoteAddr, nil
+}
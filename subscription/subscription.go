@@ -0,0 +1,701 @@
+// Package subscription converts share links and subscription payloads
+// (vmess://, vless://, trojan://, ss://) commonly copied out of v2rayN and
+// similar clients into Xray-core outbound JSON, and back.
+package subscription
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	uuidpkg "github.com/lhear/SimpleXray/common/uuid"
+)
+
+// Outbound is a minimal Xray-core outbound config: just enough structure
+// to round-trip the fields share links carry. Callers that need the full
+// Xray outbound schema can marshal this into conf.OutboundDetourConfig's
+// JSON shape directly, since the field names line up.
+type Outbound struct {
+	Tag            string          `json:"tag"`
+	Protocol       string          `json:"protocol"`
+	Settings       json.RawMessage `json:"settings"`
+	StreamSettings *StreamSettings `json:"streamSettings,omitempty"`
+}
+
+// StreamSettings mirrors the subset of Xray's transport config that share
+// links actually populate.
+type StreamSettings struct {
+	Network         string           `json:"network"`
+	Security        string           `json:"security"`
+	TLSSettings     *TLSSettings     `json:"tlsSettings,omitempty"`
+	RealitySettings *RealitySettings `json:"realitySettings,omitempty"`
+	WSSettings      *WSSettings      `json:"wsSettings,omitempty"`
+	GRPCSettings    *GRPCSettings    `json:"grpcSettings,omitempty"`
+}
+
+type TLSSettings struct {
+	ServerName    string `json:"serverName,omitempty"`
+	Fingerprint   string `json:"fingerprint,omitempty"`
+	AllowInsecure bool   `json:"allowInsecure,omitempty"`
+}
+
+type RealitySettings struct {
+	ServerName  string `json:"serverName,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	PublicKey   string `json:"publicKey,omitempty"`
+	ShortId     string `json:"shortId,omitempty"`
+	SpiderX     string `json:"spiderX,omitempty"`
+}
+
+type WSSettings struct {
+	Path    string            `json:"path,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type GRPCSettings struct {
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+// ParseError records why one share link in a subscription body failed to
+// parse, without aborting the rest of the document.
+type ParseError struct {
+	Line string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("subscription: %v (line: %.40q)", e.Err, e.Line)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// FetchSubscription downloads url and hands the body to ConvertSubscription.
+func FetchSubscription(url string) ([]Outbound, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("subscription: fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("subscription: fetch %s: status %d", url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("subscription: read %s: %w", url, err)
+	}
+	return ConvertSubscription(body)
+}
+
+// ConvertSubscription parses a subscription body - base64-encoded if it
+// decodes cleanly, otherwise treated as already-decoded - as one share
+// link per line, skipping blank lines and unparseable entries rather than
+// aborting the whole document. The errors of skipped entries are not
+// returned: the error result is non-nil only when no line parsed, and then
+// it is the first line's *ParseError.
+func ConvertSubscription(raw []byte) ([]Outbound, error) {
+	decoded := raw
+	if d, err := base64Decode(raw); err == nil {
+		decoded = d
+	}
+
+	var outbounds []Outbound
+	var errs []error
+	for _, line := range strings.Split(string(decoded), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		ob, err := ConvertLink(line)
+		if err != nil {
+			errs = append(errs, &ParseError{Line: line, Err: err})
+			continue
+		}
+		outbounds = append(outbounds, ob)
+	}
+
+	if len(outbounds) == 0 && len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return outbounds, nil
+}
+
+// ConvertLink parses a single vmess://, vless://, trojan://, or ss:// share
+// link into an Xray outbound.
+func ConvertLink(link string) (Outbound, error) {
+	switch {
+	case strings.HasPrefix(link, "vmess://"):
+		return parseVMess(link)
+	case strings.HasPrefix(link, "vless://"):
+		return parseVLESS(link)
+	case strings.HasPrefix(link, "trojan://"):
+		return parseTrojan(link)
+	case strings.HasPrefix(link, "ss://"):
+		return parseShadowsocks(link)
+	default:
+		return Outbound{}, fmt.Errorf("unsupported link scheme: %s", firstToken(link))
+	}
+}
+
+func firstToken(s string) string {
+	if idx := strings.Index(s, "://"); idx > 0 {
+		return s[:idx]
+	}
+	return s
+}
+
+// ----------------------------------------------------------------------------
+// vmess://
+// ----------------------------------------------------------------------------
+
+// vmessLegacyJSON is the legacy base64-JSON vmess:// payload shape.
+type vmessLegacyJSON struct {
+	V   string `json:"v"`
+	PS  string `json:"ps"`
+	Add string `json:"add"`
+	// Port and Aid are json.Number rather than string because real legacy
+	// vmess:// payloads are inconsistent about quoting them - some clients
+	// emit JSON numbers, others JSON strings - and json.Number unmarshals
+	// from either.
+	Port json.Number `json:"port"`
+	ID   string      `json:"id"`
+	Aid  json.Number `json:"aid"`
+	Net  string      `json:"net"`
+	Type string      `json:"type"`
+	Host string      `json:"host"`
+	Path string      `json:"path"`
+	TLS  string      `json:"tls"`
+	SNI  string      `json:"sni"`
+}
+
+func parseVMess(link string) (Outbound, error) {
+	body := strings.TrimPrefix(link, "vmess://")
+
+	// VMessAEAD form: vmess://uuid@host:port?...#name
+	if strings.Contains(body, "@") {
+		return parseVMessAEAD(body)
+	}
+
+	// Legacy form: vmess://base64(json)
+	decoded, err := base64Decode([]byte(body))
+	if err != nil {
+		return Outbound{}, fmt.Errorf("vmess: invalid base64 payload: %w", err)
+	}
+	var v vmessLegacyJSON
+	if err := json.Unmarshal(decoded, &v); err != nil {
+		return Outbound{}, fmt.Errorf("vmess: invalid json payload: %w", err)
+	}
+
+	port, err := strconv.Atoi(v.Port.String())
+	if err != nil {
+		return Outbound{}, fmt.Errorf("vmess: invalid port %q: %w", v.Port, err)
+	}
+	alterID, _ := strconv.Atoi(v.Aid.String())
+
+	network := orDefault(v.Net, "tcp")
+	security := orDefault(v.TLS, "none")
+
+	settings, _ := json.Marshal(map[string]interface{}{
+		"vnext": []map[string]interface{}{{
+			"address": v.Add,
+			"port":    port,
+			"users": []map[string]interface{}{{
+				"id":       uuidpkg.UUIDFromText(v.ID).String(),
+				"alterId":  alterID,
+				"security": "auto",
+			}},
+		}},
+	})
+
+	return Outbound{
+		Tag:      orDefault(v.PS, v.Add),
+		Protocol: "vmess",
+		Settings: settings,
+		StreamSettings: &StreamSettings{
+			Network:     network,
+			Security:    security,
+			WSSettings:  wsSettingsIfApplicable(network, v.Path, v.Host),
+			TLSSettings: tlsSettingsIfApplicable(security, orDefault(v.SNI, v.Host)),
+		},
+	}, nil
+}
+
+func parseVMessAEAD(body string) (Outbound, error) {
+	u, err := url.Parse("vmess://" + body)
+	if err != nil {
+		return Outbound{}, fmt.Errorf("vmess: %w", err)
+	}
+	uuid := u.User.Username()
+	if uuid == "" {
+		return Outbound{}, fmt.Errorf("vmess: missing uuid")
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return Outbound{}, fmt.Errorf("vmess: invalid port: %w", err)
+	}
+
+	q := u.Query()
+	network := orDefault(q.Get("type"), "tcp")
+	security := orDefault(q.Get("security"), "none")
+	alterID, _ := strconv.Atoi(orDefault(q.Get("alterId"), "0"))
+
+	settings, _ := json.Marshal(map[string]interface{}{
+		"vnext": []map[string]interface{}{{
+			"address": u.Hostname(),
+			"port":    port,
+			"users": []map[string]interface{}{{
+				"id":       uuidpkg.UUIDFromText(uuid).String(),
+				"alterId":  alterID,
+				"security": "auto",
+			}},
+		}},
+	})
+
+	return Outbound{
+		Tag:      decodeFragment(u.Fragment, u.Hostname()),
+		Protocol: "vmess",
+		Settings: settings,
+		StreamSettings: &StreamSettings{
+			Network:     network,
+			Security:    security,
+			WSSettings:  wsSettingsIfApplicable(network, q.Get("path"), q.Get("host")),
+			TLSSettings: tlsSettingsIfApplicable(security, orDefault(q.Get("sni"), q.Get("host"))),
+		},
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+// vless://
+// ----------------------------------------------------------------------------
+
+func parseVLESS(link string) (Outbound, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return Outbound{}, fmt.Errorf("vless: %w", err)
+	}
+	uuid := u.User.Username()
+	if uuid == "" {
+		return Outbound{}, fmt.Errorf("vless: missing uuid")
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return Outbound{}, fmt.Errorf("vless: invalid port: %w", err)
+	}
+
+	q := u.Query()
+	network := orDefault(q.Get("type"), "tcp")
+	security := orDefault(q.Get("security"), "none")
+	encryption := orDefault(q.Get("encryption"), "none")
+
+	settings, _ := json.Marshal(map[string]interface{}{
+		"vnext": []map[string]interface{}{{
+			"address": u.Hostname(),
+			"port":    port,
+			"users": []map[string]interface{}{{
+				"id":         uuidpkg.UUIDFromText(uuid).String(),
+				"encryption": encryption,
+				"flow":       q.Get("flow"),
+			}},
+		}},
+	})
+
+	stream := &StreamSettings{
+		Network:    network,
+		Security:   security,
+		WSSettings: wsSettingsIfApplicable(network, q.Get("path"), q.Get("host")),
+	}
+	switch security {
+	case "reality":
+		stream.RealitySettings = &RealitySettings{
+			ServerName:  orDefault(q.Get("sni"), u.Hostname()),
+			Fingerprint: q.Get("fp"),
+			PublicKey:   q.Get("pbk"),
+			ShortId:     q.Get("sid"),
+			SpiderX:     q.Get("spx"),
+		}
+	case "tls":
+		stream.TLSSettings = tlsSettingsIfApplicable(security, orDefault(q.Get("sni"), u.Hostname()))
+	}
+
+	return Outbound{
+		Tag:            decodeFragment(u.Fragment, u.Hostname()),
+		Protocol:       "vless",
+		Settings:       settings,
+		StreamSettings: stream,
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+// trojan://
+// ----------------------------------------------------------------------------
+
+func parseTrojan(link string) (Outbound, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return Outbound{}, fmt.Errorf("trojan: %w", err)
+	}
+	password := u.User.Username()
+	if password == "" {
+		return Outbound{}, fmt.Errorf("trojan: missing password")
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return Outbound{}, fmt.Errorf("trojan: invalid port: %w", err)
+	}
+
+	q := u.Query()
+	security := orDefault(q.Get("security"), "tls")
+	network := orDefault(q.Get("type"), "tcp")
+
+	settings, _ := json.Marshal(map[string]interface{}{
+		"servers": []map[string]interface{}{{
+			"address":  u.Hostname(),
+			"port":     port,
+			"password": password,
+		}},
+	})
+
+	return Outbound{
+		Tag:      decodeFragment(u.Fragment, u.Hostname()),
+		Protocol: "trojan",
+		Settings: settings,
+		StreamSettings: &StreamSettings{
+			Network:     network,
+			Security:    security,
+			TLSSettings: tlsSettingsIfApplicable(security, orDefault(q.Get("sni"), u.Hostname())),
+		},
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+// ss://
+// ----------------------------------------------------------------------------
+
+func parseShadowsocks(link string) (Outbound, error) {
+	body := strings.TrimPrefix(link, "ss://")
+	fragment := ""
+	if idx := strings.IndexByte(body, '#'); idx >= 0 {
+		fragment = body[idx+1:]
+		body = body[:idx]
+	}
+
+	var method, password, host, port string
+
+	if idx := strings.IndexByte(body, '@'); idx >= 0 {
+		// SIP002: base64(method:pass)@host:port or method:pass@host:port
+		userInfo := body[:idx]
+		hostPort := body[idx+1:]
+
+		if decoded, err := base64Decode([]byte(userInfo)); err == nil {
+			userInfo = string(decoded)
+		}
+		parts := strings.SplitN(userInfo, ":", 2)
+		if len(parts) != 2 {
+			return Outbound{}, fmt.Errorf("shadowsocks: invalid method:password")
+		}
+		method, password = parts[0], parts[1]
+
+		h, p, err := splitHostPortLoose(hostPort)
+		if err != nil {
+			return Outbound{}, fmt.Errorf("shadowsocks: %w", err)
+		}
+		host, port = h, p
+	} else {
+		// Legacy: ss://base64(method:pass@host:port)
+		decoded, err := base64Decode([]byte(body))
+		if err != nil {
+			return Outbound{}, fmt.Errorf("shadowsocks: invalid base64 payload: %w", err)
+		}
+		atIdx := strings.IndexByte(string(decoded), '@')
+		if atIdx < 0 {
+			return Outbound{}, fmt.Errorf("shadowsocks: missing '@' in decoded payload")
+		}
+		methodPass := string(decoded[:atIdx])
+		hostPort := string(decoded[atIdx+1:])
+		parts := strings.SplitN(methodPass, ":", 2)
+		if len(parts) != 2 {
+			return Outbound{}, fmt.Errorf("shadowsocks: invalid method:password")
+		}
+		method, password = parts[0], parts[1]
+		h, p, err := splitHostPortLoose(hostPort)
+		if err != nil {
+			return Outbound{}, fmt.Errorf("shadowsocks: %w", err)
+		}
+		host, port = h, p
+	}
+
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return Outbound{}, fmt.Errorf("shadowsocks: invalid port %q: %w", port, err)
+	}
+
+	settings, _ := json.Marshal(map[string]interface{}{
+		"servers": []map[string]interface{}{{
+			"address":  host,
+			"port":     portNum,
+			"method":   method,
+			"password": password,
+		}},
+	})
+
+	return Outbound{
+		Tag:      decodeFragment(fragment, host),
+		Protocol: "shadowsocks",
+		Settings: settings,
+	}, nil
+}
+
+// splitHostPortLoose splits "host:port" without requiring a valid
+// net.SplitHostPort literal IPv6 bracket form, since share links don't
+// always bracket IPv6 hosts correctly.
+func splitHostPortLoose(hostPort string) (host, port string, err error) {
+	// Strip any query/path that slipped through with the legacy form.
+	if idx := strings.IndexAny(hostPort, "/?"); idx >= 0 {
+		hostPort = hostPort[:idx]
+	}
+	idx := strings.LastIndexByte(hostPort, ':')
+	if idx < 0 {
+		return "", "", fmt.Errorf("missing port in %q", hostPort)
+	}
+	return hostPort[:idx], hostPort[idx+1:], nil
+}
+
+// ----------------------------------------------------------------------------
+// OutboundsToLinks: the inverse conversion, for re-exporting
+// ----------------------------------------------------------------------------
+
+// OutboundsToLinks renders each outbound back into its share-link form.
+// Outbounds it doesn't recognize are skipped rather than erroring.
+func OutboundsToLinks(outbounds []Outbound) []string {
+	links := make([]string, 0, len(outbounds))
+	for _, ob := range outbounds {
+		link, ok := outboundToLink(ob)
+		if ok {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+func outboundToLink(ob Outbound) (string, bool) {
+	switch ob.Protocol {
+	case "vmess":
+		return vmessOutboundToLink(ob)
+	case "vless":
+		return vlessOutboundToLink(ob)
+	case "trojan":
+		return trojanOutboundToLink(ob)
+	case "shadowsocks":
+		return shadowsocksOutboundToLink(ob)
+	default:
+		return "", false
+	}
+}
+
+// vmessOutboundToLink renders a vmess outbound back as a VMessAEAD link
+// (vmess://uuid@host:port?...#name), the form modern clients export.
+func vmessOutboundToLink(ob Outbound) (string, bool) {
+	var settings struct {
+		Vnext []struct {
+			Address string `json:"address"`
+			Port    int    `json:"port"`
+			Users   []struct {
+				ID      string `json:"id"`
+				AlterID int    `json:"alterId"`
+			} `json:"users"`
+		} `json:"vnext"`
+	}
+	if json.Unmarshal(ob.Settings, &settings) != nil || len(settings.Vnext) == 0 || len(settings.Vnext[0].Users) == 0 {
+		return "", false
+	}
+	server := settings.Vnext[0]
+	user := server.Users[0]
+
+	q := url.Values{}
+	q.Set("alterId", strconv.Itoa(user.AlterID))
+	if ob.StreamSettings != nil {
+		q.Set("type", orDefault(ob.StreamSettings.Network, "tcp"))
+		q.Set("security", orDefault(ob.StreamSettings.Security, "none"))
+		if ws := ob.StreamSettings.WSSettings; ws != nil {
+			q.Set("path", ws.Path)
+			q.Set("host", ws.Headers["Host"])
+		}
+		if t := ob.StreamSettings.TLSSettings; t != nil {
+			q.Set("sni", t.ServerName)
+		}
+	}
+
+	u := url.URL{
+		Scheme:   "vmess",
+		User:     url.User(user.ID),
+		Host:     fmt.Sprintf("%s:%d", server.Address, server.Port),
+		RawQuery: q.Encode(),
+		Fragment: ob.Tag,
+	}
+	return u.String(), true
+}
+
+func vlessOutboundToLink(ob Outbound) (string, bool) {
+	var settings struct {
+		Vnext []struct {
+			Address string `json:"address"`
+			Port    int    `json:"port"`
+			Users   []struct {
+				ID         string `json:"id"`
+				Encryption string `json:"encryption"`
+				Flow       string `json:"flow"`
+			} `json:"users"`
+		} `json:"vnext"`
+	}
+	if json.Unmarshal(ob.Settings, &settings) != nil || len(settings.Vnext) == 0 || len(settings.Vnext[0].Users) == 0 {
+		return "", false
+	}
+	server := settings.Vnext[0]
+	user := server.Users[0]
+
+	q := url.Values{}
+	q.Set("encryption", orDefault(user.Encryption, "none"))
+	if ob.StreamSettings != nil {
+		q.Set("type", orDefault(ob.StreamSettings.Network, "tcp"))
+		q.Set("security", orDefault(ob.StreamSettings.Security, "none"))
+		if user.Flow != "" {
+			q.Set("flow", user.Flow)
+		}
+		if r := ob.StreamSettings.RealitySettings; r != nil {
+			q.Set("sni", r.ServerName)
+			q.Set("fp", r.Fingerprint)
+			q.Set("pbk", r.PublicKey)
+			q.Set("sid", r.ShortId)
+			q.Set("spx", r.SpiderX)
+		}
+		if t := ob.StreamSettings.TLSSettings; t != nil {
+			q.Set("sni", t.ServerName)
+		}
+	}
+
+	u := url.URL{
+		Scheme:   "vless",
+		User:     url.User(user.ID),
+		Host:     fmt.Sprintf("%s:%d", server.Address, server.Port),
+		RawQuery: q.Encode(),
+		Fragment: ob.Tag,
+	}
+	return u.String(), true
+}
+
+func trojanOutboundToLink(ob Outbound) (string, bool) {
+	var settings struct {
+		Servers []struct {
+			Address  string `json:"address"`
+			Port     int    `json:"port"`
+			Password string `json:"password"`
+		} `json:"servers"`
+	}
+	if json.Unmarshal(ob.Settings, &settings) != nil || len(settings.Servers) == 0 {
+		return "", false
+	}
+	s := settings.Servers[0]
+
+	q := url.Values{}
+	if ob.StreamSettings != nil {
+		q.Set("security", orDefault(ob.StreamSettings.Security, "tls"))
+		q.Set("type", orDefault(ob.StreamSettings.Network, "tcp"))
+		if t := ob.StreamSettings.TLSSettings; t != nil {
+			q.Set("sni", t.ServerName)
+		}
+	}
+
+	u := url.URL{
+		Scheme:   "trojan",
+		User:     url.User(s.Password),
+		Host:     fmt.Sprintf("%s:%d", s.Address, s.Port),
+		RawQuery: q.Encode(),
+		Fragment: ob.Tag,
+	}
+	return u.String(), true
+}
+
+func shadowsocksOutboundToLink(ob Outbound) (string, bool) {
+	var settings struct {
+		Servers []struct {
+			Address  string `json:"address"`
+			Port     int    `json:"port"`
+			Method   string `json:"method"`
+			Password string `json:"password"`
+		} `json:"servers"`
+	}
+	if json.Unmarshal(ob.Settings, &settings) != nil || len(settings.Servers) == 0 {
+		return "", false
+	}
+	s := settings.Servers[0]
+	userInfo := base64.RawURLEncoding.EncodeToString([]byte(s.Method + ":" + s.Password))
+	u := url.URL{
+		Scheme:   "ss",
+		User:     url.User(userInfo),
+		Host:     fmt.Sprintf("%s:%d", s.Address, s.Port),
+		Fragment: ob.Tag,
+	}
+	return u.String(), true
+}
+
+// ----------------------------------------------------------------------------
+// Shared helpers
+// ----------------------------------------------------------------------------
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+func decodeFragment(fragment, fallback string) string {
+	if fragment == "" {
+		return fallback
+	}
+	if decoded, err := url.QueryUnescape(fragment); err == nil {
+		return decoded
+	}
+	return fragment
+}
+
+func wsSettingsIfApplicable(network, path, host string) *WSSettings {
+	if network != "ws" {
+		return nil
+	}
+	ws := &WSSettings{Path: orDefault(path, "/")}
+	if host != "" {
+		ws.Headers = map[string]string{"Host": host}
+	}
+	return ws
+}
+
+func tlsSettingsIfApplicable(security, sni string) *TLSSettings {
+	if security != "tls" {
+		return nil
+	}
+	return &TLSSettings{ServerName: sni}
+}
+
+// base64Decode tries standard, URL-safe, and unpadded variants, since
+// share links are inconsistent about which encoding they use.
+func base64Decode(data []byte) ([]byte, error) {
+	s := strings.TrimSpace(string(data))
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.RawURLEncoding,
+	} {
+		if decoded, err := enc.DecodeString(s); err == nil {
+			return decoded, nil
+		}
+	}
+	return nil, fmt.Errorf("no base64 variant matched")
+}
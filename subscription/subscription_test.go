@@ -0,0 +1,184 @@
+package subscription
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestConvertLink(t *testing.T) {
+	cases := []struct {
+		name         string
+		link         string
+		wantProtocol string
+		wantTag      string
+		wantErr      bool
+	}{
+		{
+			name:         "vmess AEAD form",
+			link:         "vmess://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443?type=ws&security=tls&path=%2Fws&host=example.com&sni=example.com#my-node",
+			wantProtocol: "vmess",
+			wantTag:      "my-node",
+		},
+		{
+			name:         "vmess legacy base64 JSON form",
+			link:         "vmess://" + base64.StdEncoding.EncodeToString([]byte(`{"v":"2","ps":"legacy-node","add":"example.com","port":"443","id":"b831381d-6324-4d53-ad4f-8cda48b30811","aid":"0","net":"tcp","type":"none","host":"","path":"","tls":"none"}`)),
+			wantProtocol: "vmess",
+			wantTag:      "legacy-node",
+		},
+		{
+			name:         "vmess legacy base64 JSON form with numeric port/aid",
+			link:         "vmess://" + base64.StdEncoding.EncodeToString([]byte(`{"v":"2","ps":"legacy-numeric-node","add":"example.com","port":443,"id":"b831381d-6324-4d53-ad4f-8cda48b30811","aid":0,"net":"tcp","type":"none","host":"","path":"","tls":"none"}`)),
+			wantProtocol: "vmess",
+			wantTag:      "legacy-numeric-node",
+		},
+		{
+			name:         "vless with reality",
+			link:         "vless://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443?encryption=none&security=reality&sni=example.com&fp=chrome&pbk=pubkey&sid=abcd&type=tcp#vless-node",
+			wantProtocol: "vless",
+			wantTag:      "vless-node",
+		},
+		{
+			name:         "trojan",
+			link:         "trojan://password@example.com:443?sni=example.com#trojan-node",
+			wantProtocol: "trojan",
+			wantTag:      "trojan-node",
+		},
+		{
+			name:         "shadowsocks plain method:pass form",
+			link:         "ss://aes-256-gcm:password@example.com:8388#ss-node",
+			wantProtocol: "shadowsocks",
+			wantTag:      "ss-node",
+		},
+		{
+			name:         "shadowsocks SIP002 base64 userinfo form",
+			link:         "ss://" + base64.RawURLEncoding.EncodeToString([]byte("aes-256-gcm:password")) + "@example.com:8388#ss2-node",
+			wantProtocol: "shadowsocks",
+			wantTag:      "ss2-node",
+		},
+		{
+			name:    "unsupported scheme",
+			link:    "http://example.com",
+			wantErr: true,
+		},
+		{
+			name:    "vless missing uuid",
+			link:    "vless://@example.com:443",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ob, err := ConvertLink(tc.link)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ConvertLink(%q) = nil error, want error", tc.link)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertLink(%q) unexpected error: %v", tc.link, err)
+			}
+			if ob.Protocol != tc.wantProtocol {
+				t.Errorf("protocol = %q, want %q", ob.Protocol, tc.wantProtocol)
+			}
+			if ob.Tag != tc.wantTag {
+				t.Errorf("tag = %q, want %q", ob.Tag, tc.wantTag)
+			}
+		})
+	}
+}
+
+func TestConvertSubscriptionSkipsUnparseableLines(t *testing.T) {
+	raw := []byte("vless://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443?encryption=none#ok\nnot-a-link\ntrojan://password@example.com:443#ok2\n")
+	outbounds, err := ConvertSubscription(raw)
+	if err != nil {
+		t.Fatalf("ConvertSubscription returned error: %v", err)
+	}
+	if len(outbounds) != 2 {
+		t.Fatalf("got %d outbounds, want 2", len(outbounds))
+	}
+}
+
+func TestConvertSubscriptionBase64Wrapped(t *testing.T) {
+	inner := "trojan://password@example.com:443#wrapped"
+	raw := []byte(base64.StdEncoding.EncodeToString([]byte(inner)))
+	outbounds, err := ConvertSubscription(raw)
+	if err != nil {
+		t.Fatalf("ConvertSubscription returned error: %v", err)
+	}
+	if len(outbounds) != 1 || outbounds[0].Tag != "wrapped" {
+		t.Fatalf("ConvertSubscription(base64) = %+v, want one outbound tagged wrapped", outbounds)
+	}
+}
+
+func TestOutboundsToLinksRoundTrip(t *testing.T) {
+	links := []string{
+		"vmess://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443?type=ws&security=tls&path=%2Fws&host=example.com&sni=example.com#my-node",
+		"vless://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443?encryption=none&type=tcp&security=none#vless-node",
+		"trojan://password@example.com:443#trojan-node",
+		"ss://aes-256-gcm:password@example.com:8388#ss-node",
+	}
+
+	var outbounds []Outbound
+	for _, link := range links {
+		ob, err := ConvertLink(link)
+		if err != nil {
+			t.Fatalf("ConvertLink(%q): %v", link, err)
+		}
+		outbounds = append(outbounds, ob)
+	}
+
+	got := OutboundsToLinks(outbounds)
+	if len(got) != len(links) {
+		t.Fatalf("OutboundsToLinks returned %d links, want %d (vmess outbounds must round-trip too): %v", len(got), len(links), got)
+	}
+
+	protocols := make(map[string]bool)
+	for _, ob := range outbounds {
+		protocols[ob.Protocol] = true
+	}
+	if !protocols["vmess"] {
+		t.Fatalf("test setup missing a vmess outbound")
+	}
+
+	sawVmess := false
+	for _, link := range got {
+		if len(link) >= len("vmess://") && link[:len("vmess://")] == "vmess://" {
+			sawVmess = true
+		}
+	}
+	if !sawVmess {
+		t.Errorf("OutboundsToLinks(%v) dropped the vmess outbound, got %v", outbounds, got)
+	}
+}
+
+func TestVLESSRealityFieldsPreserved(t *testing.T) {
+	link := "vless://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443?encryption=none&security=reality&sni=example.com&fp=chrome&pbk=pubkey&sid=abcd&spx=%2F#reality-node"
+	ob, err := ConvertLink(link)
+	if err != nil {
+		t.Fatalf("ConvertLink: %v", err)
+	}
+	if ob.StreamSettings == nil || ob.StreamSettings.RealitySettings == nil {
+		t.Fatalf("expected RealitySettings to be populated, got %+v", ob.StreamSettings)
+	}
+	r := ob.StreamSettings.RealitySettings
+	if r.PublicKey != "pubkey" || r.ShortId != "abcd" || r.Fingerprint != "chrome" {
+		t.Errorf("RealitySettings = %+v, unexpected field values", r)
+	}
+
+	var settings struct {
+		Vnext []struct {
+			Users []struct {
+				ID string `json:"id"`
+			} `json:"users"`
+		} `json:"vnext"`
+	}
+	if err := json.Unmarshal(ob.Settings, &settings); err != nil {
+		t.Fatalf("unmarshal settings: %v", err)
+	}
+	if len(settings.Vnext) != 1 || len(settings.Vnext[0].Users) != 1 {
+		t.Fatalf("unexpected settings shape: %s", ob.Settings)
+	}
+}
@@ -0,0 +1,211 @@
+// Package validate dry-runs an Xray JSON config through the same loaders
+// and construction path main uses, without ever calling Start(), so a GUI
+// wrapper can surface inline feedback before binding a port or dialing
+// anything for real.
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/xtls/xray-core/core"
+)
+
+// Kind classifies where in the validation pipeline a ValidationError
+// originated, so callers can decide how to present it.
+type Kind string
+
+const (
+	// KindParse means the input wasn't valid JSON (or the chosen format).
+	KindParse Kind = "parse"
+	// KindReference means the config parsed but refers to something that
+	// doesn't exist - an unknown outbound tag in a routing rule or
+	// balancer, a malformed Reality/TLS field, and the like.
+	KindReference Kind = "reference"
+	// KindConstruct means core.New itself rejected the config while
+	// building the instance graph.
+	KindConstruct Kind = "construct"
+)
+
+// ValidationError is a structured alternative to the plain strings
+// core.LoadConfig/core.New normally return, carrying a best-effort JSON
+// path (e.g. "outbounds[2].streamSettings.realitySettings.publicKey") a
+// GUI can use to highlight the offending field.
+type ValidationError struct {
+	Path    string
+	Message string
+	Kind    Kind
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Kind, e.Path, e.Message)
+}
+
+// ValidateConfig parses data in the given format (the same formats
+// core.LoadConfig/main accept: "json", "yaml", "toml", ...), resolves all
+// references, and constructs a core.Instance - but never calls Start(),
+// so no listener is bound and no upstream connection is made.
+func ValidateConfig(format string, data []byte) error {
+	cfg, err := core.LoadConfig(format, bytes.NewReader(data))
+	if err != nil {
+		return &ValidationError{
+			Path:    jsonErrorPath(data, err),
+			Message: err.Error(),
+			Kind:    KindParse,
+		}
+	}
+	return ValidateCoreConfig(cfg)
+}
+
+// ValidateCoreConfig dry-runs an already-parsed *core.Config the same
+// way ValidateConfig does: construct, never Start, always Close.
+func ValidateCoreConfig(cfg *core.Config) error {
+	instance, err := core.New(cfg)
+	if err != nil {
+		return &ValidationError{
+			Path:    "",
+			Message: err.Error(),
+			Kind:    constructErrorKind(err),
+		}
+	}
+	// core.New already resolved every tag/reference and built the
+	// instance graph; nothing left to do but release it without ever
+	// starting a listener or dialing out.
+	return instance.Close()
+}
+
+// constructErrorKind guesses KindReference vs KindConstruct from the
+// error text core.New produces, since xray-core doesn't expose a typed
+// error for "unknown tag" vs "bad field construction".
+func constructErrorKind(err error) Kind {
+	msg := err.Error()
+	for _, marker := range []string{"not found", "unknown", "undefined", "doesn't exist"} {
+		if contains(msg, marker) {
+			return KindReference
+		}
+	}
+	return KindConstruct
+}
+
+func contains(haystack, needle string) bool {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if haystack[i:i+len(needle)] == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonErrorPath makes a best-effort attempt to translate a JSON decode
+// error's byte offset into a dotted/indexed path like
+// "outbounds[2].streamSettings", by walking the raw token stream up to
+// that offset and tracking which object keys and array indices are
+// currently open. It's necessarily approximate - encoding/json doesn't
+// expose per-token positions well enough to be exact about where inside
+// a multi-line value the offset falls - but it gets a GUI close enough
+// to highlight the right field. Returns "" if the underlying error
+// doesn't carry a byte offset at all.
+func jsonErrorPath(data []byte, err error) string {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return ""
+	}
+
+	var stack []frame
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.InputOffset() < offset {
+		tok, tokErr := dec.Token()
+		if tokErr != nil {
+			break
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{', '[':
+				stack = append(stack, frame{isArray: t == '['})
+			case '}', ']':
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+				bumpParentIndex(stack)
+			}
+		default:
+			if len(stack) == 0 {
+				continue
+			}
+			top := &stack[len(stack)-1]
+			if top.isArray {
+				// A scalar array element just finished; advance the index
+				// for the next one.
+				top.index++
+			} else if !top.sawKey {
+				if key, ok := t.(string); ok {
+					top.key = key
+					top.sawKey = true
+				}
+			} else {
+				// Scalar value consumed for the pending key.
+				top.sawKey = false
+			}
+		}
+	}
+
+	path := pathFromStack(stack)
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
+
+// bumpParentIndex advances the enclosing array frame's index after a
+// nested object/array element closes, mirroring what the scalar-element
+// branch in jsonErrorPath does for scalar elements.
+func bumpParentIndex(stack []frame) {
+	if len(stack) == 0 {
+		return
+	}
+	top := &stack[len(stack)-1]
+	if top.isArray {
+		top.index++
+	} else {
+		top.sawKey = false
+	}
+}
+
+// frame tracks one open JSON container while walking the token stream in
+// jsonErrorPath: the key/index last seen at this nesting level.
+type frame struct {
+	key     string // most recent key at this object level
+	index   int    // current element index at this array level
+	isArray bool
+	sawKey  bool // true once an object frame has read its key, awaiting value
+}
+
+func pathFromStack(stack []frame) string {
+	var out string
+	for _, f := range stack {
+		if f.isArray {
+			out += fmt.Sprintf("[%d]", f.index)
+			continue
+		}
+		if f.key == "" {
+			continue
+		}
+		if out != "" {
+			out += "."
+		}
+		out += f.key
+	}
+	return out
+}